@@ -25,6 +25,27 @@ func (cg *ChannelGroups) GetGroupById(id uint64) *ChannelGroup {
 	return nil
 }
 
+// HasChannel checks the channel exists in any group.
+func (cg *ChannelGroups) HasChannel(id uint64) bool {
+	for _, group := range *cg {
+		if group.Channels.GetChannelById(id) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// GetGroupByTitle returns the group by given title, case and punctuation are ignored.
+func (cg *ChannelGroups) GetGroupByTitle(title string) *ChannelGroup {
+	slug := conply.Slug(title)
+	for _, group := range *cg {
+		if conply.Slug(group.Title) == slug {
+			return group
+		}
+	}
+	return nil
+}
+
 // PrettyPrint builds a human-readable list of a groups.
 func (cg *ChannelGroups) PrettyPrint() string {
 	var res []string
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/koykov/conply"
+)
+
+// Run the command instead of playing and exit.
+func runCommand(cmd string) {
+	if err := conply.PrepareEnv(Bundle); err != nil {
+		verbose.Fail("Error preparing the environment: ", err)
+		os.Exit(1)
+	}
+
+	var err error
+	switch cmd {
+	case "list":
+		err = list()
+	case "export":
+		err = export()
+	case "proxy":
+		err = serveProxy(*proxy)
+	case "history":
+		err = history()
+	default:
+		verbose.Failf("101ply: unknown command \"%s\"\nTry \"101ply --help\" for more information", cmd)
+		os.Exit(1)
+	}
+	if err != nil {
+		verbose.Fail(err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// Print groups and channels, optionally filtered by --match.
+func list() error {
+	if err := ply.LoadChannels(options["noCache"].(bool)); err != nil {
+		return err
+	}
+	cat := ply.Catalog()
+	if len(*match) > 0 {
+		cat = cat.Filter(*match)
+	}
+	var buf bytes.Buffer
+	if err := cat.Write(&buf, *format); err != nil {
+		return err
+	}
+	return output(&buf)
+}
+
+// Export groups and channels as a playlist of local proxy URLs.
+// Channels of 101.ru haven't a permanent stream URLs, so each entry points to the stream proxy.
+func export() error {
+	if err := ply.LoadChannels(options["noCache"].(bool)); err != nil {
+		return err
+	}
+
+	pl := conply.Playlist{Title: Bundle}
+	for _, group := range ply.cache {
+		for _, channel := range group.Channels {
+			pl.Add(fmt.Sprintf("%s / %s", group.Title, channel.Title), fmt.Sprintf("http://%s/channel/%d", *proxy, channel.Id))
+		}
+	}
+
+	f := *format
+	if len(f) == 0 {
+		f = conply.PlaylistFormat(*file)
+	}
+	var buf bytes.Buffer
+	if err := pl.Write(&buf, f); err != nil {
+		return err
+	}
+	return output(&buf)
+}
+
+// Export listening history.
+func history() error {
+	f, err := conply.NewHistoryFilter(*from, *to)
+	if err != nil {
+		return err
+	}
+	f.Channel = *match
+	// Channel ID or title given by -c works as well as --match.
+	if id, err := strconv.ParseUint(*channel, 10, 64); err == nil {
+		f.ChannelId = id
+	} else if len(f.Channel) == 0 {
+		f.Channel = *channel
+	}
+
+	path, _ := conply.GetHistoryPath(Bundle)
+	recs, err := conply.HistoryFromFile(path)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := recs.Filter(f).Write(&buf, *format); err != nil {
+		return err
+	}
+	return output(&buf)
+}
+
+// Write command output to the file or stdout.
+func output(buf *bytes.Buffer) error {
+	if len(*file) == 0 {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := conply.FilePut(*file, buf.String()); err != nil {
+		return err
+	}
+	verbose.Debug1("Output has been written to ", *file)
+	return nil
+}
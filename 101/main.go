@@ -17,13 +17,35 @@ import (
 )
 
 var (
-	ply     *Player
-	options conply.Options
-	keybind *kb.Keybind
-	verbose *v.Verbose
+	ply         *Player
+	options     conply.Options
+	keybind     *kb.Keybind
+	mpris       *conply.MPRIS
+	control     *conply.Control
+	api         *conply.API
+	tui         *conply.TUI
+	evOutput    *conply.Output
+	termKeys    *conply.TermKeys
+	termHotkeys []*kb.Hotkey
+	verbose     *v.Verbose
+	command     string
 
 	nc       = multiflag.Bools([]string{"no-cache", "nc"}, false, "Ignore cache")
-	channel  = multiflag.Ints([]string{"channel", "c"}, 0, "Channel ID.")
+	channel  = multiflag.Strings([]string{"channel", "c"}, "", "Channel ID, title or part of it, eg \"classic rock\"")
+	fav      = multiflag.String("fav", "", "Favorite channel name.")
+	resume   = multiflag.Bool("resume", false, "Resume the last session instead of asking the channel.")
+	format   = multiflag.String("format", "", "Output format: table, json or tsv for list, m3u, pls or xspf for export, csv or json for history")
+	file     = multiflag.Strings([]string{"file", "f"}, "", "Write command or events output to the file or FIFO instead of stdout")
+	from     = multiflag.String("from", "", "History start date, eg 2006-01-02 or \"2006-01-02 15:04\"")
+	to       = multiflag.String("to", "", "History end date, eg 2006-01-02 or \"2006-01-02 15:04\"")
+	match    = multiflag.String("match", "", "Part of the channel title to filter list and history")
+	proxy    = multiflag.String("proxy", ProxyAddr, "Address of the local stream proxy")
+	httpAddr = multiflag.String("http", "", "Address of HTTP API, eg 127.0.0.1:8180. Disabled by default")
+	tuiMode  = multiflag.Bool("tui", false, "Full-screen terminal UI")
+	outFmt   = multiflag.String("output", "", "Machine-readable output of player events: jsonl or text, see -f")
+	sleepFor = multiflag.String("sleep", "", "Sleep timer, eg 45m. Playing fades out and stops when the time is over")
+	wakeAt   = multiflag.String("wake", "", "Wake up alarm, eg 07:30. Playing starts at the given time with volume ramp")
+	token    = multiflag.String("http-token", os.Getenv("CONPLY_HTTP_TOKEN"), "Token of HTTP API, required for non-loopback address")
 	verbose1 = multiflag.Bool("v", false, "Verbosity level 1")
 	verbose2 = multiflag.Bool("vv", false, "Verbosity level 2")
 	verbose3 = multiflag.Bool("vvv", false, "Verbosity level 3")
@@ -31,7 +53,16 @@ var (
 	sigStop = make(chan os.Signal)
 )
 
-func init() {
+// Parse the command line and prepare the player. Commands exit after running.
+// It isn't done in init, so tests of the package may run.
+func setup() {
+	// Check command mode.
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+		// Omit command name to parse flags properly.
+		os.Args = os.Args[1:]
+	}
+
 	multiflag.Parse()
 
 	options = conply.Options{}
@@ -50,10 +81,51 @@ func init() {
 	// Cache control.
 	options["noCache"] = *nc
 	// Predefined channel.
-	options["channel"] = uint64(*channel)
+	options["channel"] = *channel
+	// Favorite channel.
+	options["favorite"] = *fav
+	// Resume the last session.
+	options["resume"] = *resume
+	// Sleep timer.
+	options["sleep"] = time.Duration(0)
+	if len(*sleepFor) > 0 {
+		d, err := time.ParseDuration(*sleepFor)
+		if err != nil || d <= 0 {
+			v.NewVerbose(v.LevelFail).Failf("101ply: invalid sleep timer \"%s\"\nTry \"101ply --help\" for more information", *sleepFor)
+			os.Exit(1)
+		}
+		options["sleep"] = d
+	}
+	// Wake up alarm.
+	options["wake"] = time.Time{}
+	if len(*wakeAt) > 0 {
+		t, err := conply.NextClock(*wakeAt, time.Now())
+		if err != nil {
+			v.NewVerbose(v.LevelFail).Failf("101ply: invalid wake up time \"%s\"\nTry \"101ply --help\" for more information", *wakeAt)
+			os.Exit(1)
+		}
+		options["wake"] = t
+	}
 
 	verbose = v.NewVerbose(options["verboseLevel"].(v.VerbosityLevel))
 	ply = NewPlayer(verbose, options)
+
+	// Commands doesn't need neither VLC nor hotkeys.
+	if len(command) > 0 {
+		runCommand(command)
+	}
+
+	// Write events for status bars and scripts.
+	if len(*outFmt) > 0 {
+		var err error
+		if evOutput, err = conply.NewOutput(*outFmt, *file); err != nil {
+			verbose.Fail("Couldn't open events output: ", err)
+			os.Exit(1)
+		}
+		ply.events.Subscribe(evOutput.Listen)
+	}
+
+	verbose.Info(Bundle + " " + Version)
 	verbose.Debug1f("Init options:\n%s", options.PrettyPrint())
 	if err := ply.Init(); err != nil {
 		verbose.Fail("Initialization failed due to error: ", err)
@@ -64,18 +136,43 @@ func init() {
 
 	// Init keybinding.
 	hkPath, _ := conply.GetHKPath(Bundle)
-	keybind = kb.NewKeybind(ply)
-	if err := keybind.LoadFromFile(hkPath); err != nil {
+	hotkeys, err := conply.LoadHotkeys(hkPath)
+	if err != nil {
 		verbose.Fail("Hotkeys will unavailable during this session due to error: ", err)
 	}
+	xkeys, tkeys := conply.SplitHotkeys(hotkeys)
+	if len(tkeys) == 0 {
+		// Config was created before terminal keys appeared.
+		_, tkeys = conply.SplitHotkeys(defaultHotkeys)
+	}
+	termHotkeys = tkeys
+	keybind = kb.NewKeybind(ply)
+	keybind.SetHotkeys(xkeys)
 	if err := keybind.Init(); err != nil {
-		verbose.Fail("Hotkeys will unavailable during this session due to error: ", err)
+		keybind = nil
+		verbose.Warning("X hotkeys will unavailable during this session, terminal keys will be used instead: ", err)
+	}
+
+	// Expose the player to desktop environment.
+	if mpris, err = conply.NewMPRIS(Bundle, ply, &ply.np); err != nil {
+		verbose.Warning("MPRIS interface will unavailable during this session due to error: ", err)
+	} else {
+		ply.events.Subscribe(mpris.Listen)
+	}
+
+	// Listen commands from scripts and other terminals.
+	if control, err = conply.NewControl(Bundle, ply, &ply.np); err != nil {
+		verbose.Warning("Control socket will unavailable during this session due to error: ", err)
 	}
 }
 
 func main() {
+	setup()
+
 	// Wait for hotkeys.
-	go keybind.Wait()
+	if keybind != nil {
+		go keybind.Wait()
+	}
 
 	// Register cleanup callback.
 	signal.Notify(sigStop, os.Interrupt, syscall.SIGTERM)
@@ -91,36 +188,81 @@ func main() {
 	}()
 
 	verbose.Debug1("Get groups and channels")
+	if err := ply.LoadChannels(options["noCache"].(bool)); err != nil {
+		verbose.Fail("Couldn't get groups and channels: ", err)
+		_ = conply.Halt(1)
+	}
 
-	// Check cache first.
-	cacheFile, _ := conply.GetChannelsPath(Bundle)
-	verbose.Debug2("Look for data in cache ", cacheFile)
-	regenRequire := !conply.FileExists(cacheFile) || conply.FileAge(cacheFile) > CacheExpire || options["noCache"].(bool)
-	if regenRequire {
-		verbose.Debug2(`Cache is invalid or expired or "--no-cache" options has applied, try to regenerate it`)
-		verbose.Debug2("Looking for groups and channels in remote site")
-		if err := ply.RetrieveTree(); err != nil {
-			verbose.Fail("Couldn't retrieve data from remote site: ", err)
-			_ = conply.Halt(1)
+	// Start HTTP API.
+	if len(*httpAddr) > 0 {
+		var err error
+		if api, err = conply.NewAPI(Bundle, *httpAddr, *token, ply, &ply.np); err != nil {
+			verbose.Warning("HTTP API will unavailable during this session due to error: ", err)
 		} else {
-			verbose.Debug2f("Groups and channels list has been retrieved from remote site, total groups retrieved: %d", len(ply.cache))
-			if err := conply.MarshalFile(cacheFile, ply.cache, true); err != nil {
-				verbose.Fail("Writing cache error: ", err)
-			} else {
-				verbose.Debug3("Groups and channels list has been saved in cache file ", cacheFile)
-			}
+			ply.events.Subscribe(api.Listen)
+			verbose.Infof("HTTP API is listening on http://%s", *httpAddr)
 		}
-	} else {
+	}
+
+	// Start terminal UI.
+	if *tuiMode {
 		var err error
-		verbose.Debug2("Get channels from cache")
-		if ply.cache, err = ChannelsFromCache(cacheFile); err != nil {
-			verbose.Fail("Cache reading problem: ", err)
+		if tui, err = conply.NewTUI(Bundle, ply, &ply.np); err != nil {
+			verbose.Warning("Terminal UI will unavailable during this session due to error: ", err)
+		} else {
+			ply.events.Subscribe(tui.Listen)
+		}
+	}
+
+	// Look for predefined channel.
+	if query := options["channel"].(string); len(query) > 0 {
+		if m, err := ply.LookupChannel(query, nil); err != nil {
+			failLookup(query, err)
+			verbose.Fail("Exiting.")
 			_ = conply.Halt(1)
 		} else {
-			verbose.Debug3f("Groups and channels list has been retrieved from cache, total groups retrieved: %d", len(ply.cache))
+			ply.chIdx = m.Id
 		}
 	}
 
+	// Check favorite channel.
+	if name := options["favorite"].(string); len(name) > 0 {
+		fav := ply.favs.Get(name)
+		if fav == nil {
+			verbose.Failf("Favorite \"%s\" doesn't exists. Exiting.", name)
+			_ = conply.Halt(1)
+		} else {
+			ply.chIdx = fav.Channel
+		}
+	}
+
+	// Check the last session.
+	if options["resume"].(bool) && ply.chIdx == 0 {
+		path, _ := conply.GetSessionPath(Bundle)
+		if session, err := conply.SessionFromFile(path); err != nil {
+			verbose.Warning("Couldn't resume the last session: ", err)
+		} else {
+			verbose.Debug1f("Resume the last session: %#v", session)
+			ply.chIdx = session.Channel
+			if session.Paused {
+				verbose.Info("Session is resumed in paused state")
+				ply.setStatus(conply.StatusPause)
+			}
+		}
+	}
+
+	// Channel of the last session or favorite may be removed from the site since then.
+	if ply.chIdx > 0 && !ply.cache.HasChannel(ply.chIdx) {
+		verbose.Warningf("Channel %d isn't found in the catalog, choose another one", ply.chIdx)
+		ply.chIdx = 0
+	}
+
+	// Terminal UI has own channels browser.
+	if tui != nil && ply.chIdx == 0 {
+		verbose.Info("Choose the channel to play")
+		ply.chIdx = <-ply.switchTo
+	}
+
 	// Ask group/channel ID.
 	if ply.chIdx > 0 {
 		verbose.Debug1f("Channel predefined: %d", ply.chIdx)
@@ -136,15 +278,21 @@ func main() {
 				"label_uc": "Channel",
 			},
 		}
+		if len(ply.favs) > 0 {
+			verbose.Infof("Favorites:\n%s", ply.favs.PrettyPrint())
+		}
+		picked := false
 		for k, bundle := range bundles {
+			if picked {
+				break
+			}
 			verbose.Debug1f("Ask for %s to play", bundle["label"])
 			reader := bufio.NewReader(os.Stdin)
 			switch k {
 			case 0:
-				verbose.Infof("%s ID:\n%s", bundle["label_uc"], ply.cache.PrettyPrint())
+				verbose.Infof("%s ID, title, favorite name or channel title:\n%s", bundle["label_uc"], ply.cache.PrettyPrint())
 			case 1:
-				group := ply.cache.GetGroupById(ply.grIdx)
-				verbose.Infof("%s ID:\n%s", bundle["label_uc"], group.Channels.PrettyPrint())
+				verbose.Infof("%s ID or title (add * to star the channel, eg 42*):\n%s", bundle["label_uc"], ply.group.Channels.PrettyPrint())
 			}
 			attempts := 0
 			for {
@@ -155,20 +303,62 @@ func main() {
 				var idxParsed uint64
 				if err != nil {
 					fail = true
-					verbose.Failf("Couldn't receive %s ID from stdin: ", bundle["label"], err)
+					verbose.Failf("Couldn't receive %s ID from stdin: %s", bundle["label"], err)
 				}
 				verbose.Debug2f("Raw value you specified: %#v", idx)
+				idx = strings.Trim(idx, "\n")
+				star := strings.HasSuffix(idx, "*")
+				idx = strings.TrimSuffix(idx, "*")
+				if fav := ply.favs.Get(idx); k == 0 && fav != nil {
+					verbose.Debug3f("Favorite from raw value: %s", fav.Name)
+					ply.chIdx = fav.Channel
+					ply.group, ply.channel = ply.GetByChannelId(ply.chIdx)
+					picked = true
+					break
+				}
+				if group := ply.cache.GetGroupByTitle(idx); k == 0 && group != nil {
+					verbose.Debug3f("Group from raw value: %s", group.Title)
+					ply.grIdx, ply.group = group.Id, group
+					break
+				}
+				// Titles are looked up among all channels on group step and among the group channels on channel step.
+				if _, err := strconv.ParseUint(idx, 10, 64); err != nil {
+					var group *ChannelGroup
+					if k == 1 {
+						group = ply.group
+					}
+					m, err := ply.LookupChannel(idx, group)
+					if err == nil {
+						verbose.Debug3f("Channel from raw value: %s", m)
+						ply.chIdx = m.Id
+						ply.group, ply.channel = ply.GetByChannelId(ply.chIdx)
+						if star {
+							if err := ply.ToggleFavorite(); err != nil {
+								verbose.Fail("Couldn't save favorites: ", err)
+							}
+						}
+						picked = true
+						break
+					}
+					failLookup(idx, err)
+					if attempts >= 3 {
+						verbose.Failf("Oops, you've specified wrong %s %d times. Exiting.", bundle["label"], attempts)
+						_ = conply.Halt(1)
+						break
+					}
+					continue
+				}
 				switch k {
 				case 0:
-					ply.grIdx, err = strconv.ParseUint(strings.Trim(idx, "\n"), 10, 64)
+					ply.grIdx, err = strconv.ParseUint(idx, 10, 64)
 					idxParsed = ply.grIdx
 				case 1:
-					ply.chIdx, err = strconv.ParseUint(strings.Trim(idx, "\n"), 10, 64)
+					ply.chIdx, err = strconv.ParseUint(idx, 10, 64)
 					idxParsed = ply.chIdx
 				}
 				if err != nil {
 					fail = true
-					verbose.Failf("Couldn't convert value to %s ID: ", bundle["label"], err)
+					verbose.Failf("Couldn't convert value to %s ID: %s", bundle["label"], err)
 				} else {
 					verbose.Debug3f("%s ID from raw value: %v", bundle["label_uc"], idxParsed)
 				}
@@ -188,6 +378,11 @@ func main() {
 						verbose.Failf("%s ID you specified doesn't exists, try again", bundle["label_uc"])
 					} else {
 						ply.channel = channel
+						if star {
+							if err := ply.ToggleFavorite(); err != nil {
+								verbose.Fail("Couldn't save favorites: ", err)
+							}
+						}
 					}
 				}
 
@@ -203,6 +398,26 @@ func main() {
 		}
 	}
 
+	// Use terminal keys if X server is unavailable. Stdin is free since the channel has chosen.
+	if keybind == nil && tui == nil {
+		var err error
+		if termKeys, err = conply.NewTermKeys(ply, termHotkeys); err != nil {
+			verbose.Fail("Hotkeys will unavailable during this session due to error: ", err)
+		} else {
+			go termKeys.Wait()
+		}
+	}
+
+	// Idle until the wake up time, tracks aren't requested meanwhile.
+	if wake := options["wake"].(time.Time); !wake.IsZero() {
+		verbose.Infof("Playing starts at %s", wake.Format("15:04"))
+		conply.WaitUntil(wake)
+		ply.wakeRamp = conply.WakeFadeDuration
+	}
+	if d := options["sleep"].(time.Duration); d > 0 {
+		ply.SetSleep(d)
+	}
+
 	verbose.Infof("Playing: %s/%s", ply.group.Title, ply.channel.Title)
 	// Playing loop.
 	attempts := 0
@@ -223,15 +438,33 @@ func main() {
 		}
 		if ply.trackUid != ply.prevTrackUid {
 			verbose.Info(ply.track.ComposeTitle())
+			ply.finishTrack(false)
 			err = ply.Play()
 			if err != nil {
 				verbose.Fail("Play failed due to error: ", err)
 				ply.nextFetch = DelayAfterFail
+			} else {
+				ply.startTrack()
 			}
 		}
 		select {
 		case <-ply.ticks["track"]:
 			// Just waste the time.
+		case cid := <-ply.switchTo:
+			ply.finishTrack(true)
+			ply.chIdx, ply.prevTrackUid = cid, 0
+			ply.group, ply.channel = ply.GetByChannelId(cid)
+			verbose.Infof("Playing: %s/%s", ply.group.Title, ply.channel.Title)
+			attempts = 0
 		}
 	}
 }
+
+// Report failed channel lookup.
+func failLookup(query string, err error) {
+	if err == conply.ErrUnknownChannel {
+		verbose.Failf("Channel \"%s\" doesn't exists", query)
+	} else {
+		verbose.Fail(err)
+	}
+}
@@ -6,21 +6,19 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os/exec"
-	"path"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	kb "github.com/koykov/helpers/keybind"
 	v "github.com/koykov/helpers/verbose"
-	"github.com/koykov/vlc"
 	"github.com/mikkyang/id3-go"
 
 	"github.com/koykov/conply"
+	"github.com/koykov/conply/vlc"
 )
 
 const (
@@ -28,13 +26,35 @@ const (
 	Version        = "v0.1"
 	CacheExpire    = 7 * 24 * 3600
 	DelayAfterFail = 5
+	ProxyAddr      = "127.0.0.1:8101"
 )
 
+// Default hotkeys. Keys with "Term-" prefix are used in terminal when X server is unavailable.
+var defaultHotkeys = []*kb.Hotkey{
+	{"Pause", "sig-toggle-pause"},
+	{"Control-Shift-k", "sig-toggle-pause"},
+	{"Control-Shift-d", "sig-download"},
+	{"Control-Shift-f", "sig-favorite"},
+	{"Control-Shift-Up", "sig-volume-up"},
+	{"Control-Shift-Down", "sig-volume-down"},
+	{"Control-Shift-m", "sig-mute"},
+	{"Control-Shift-s", "sig-sleep"},
+	{"Term-space", "sig-toggle-pause"},
+	{"Term-d", "sig-download"},
+	{"Term-f", "sig-favorite"},
+	{"Term-+", "sig-volume-up"},
+	{"Term--", "sig-volume-down"},
+	{"Term-m", "sig-mute"},
+	{"Term-s", "sig-sleep"},
+	{"Term-q", conply.SigQuit},
+}
+
 type Player struct {
 	cache        ChannelGroups
 	group        *ChannelGroup
 	channel      *ChannelCache
 	track        *Track
+	favs         conply.Favorites
 	grIdx        uint64
 	chIdx        uint64
 	nextFetch    uint64
@@ -46,6 +66,19 @@ type Player struct {
 	ticks    map[string]<-chan time.Time
 	sigUtime int64
 	muxDl    sync.Mutex
+	dl       conply.Downloads
+
+	events conply.Events
+	info   *conply.TrackInfo
+	np     conply.NowPlaying
+
+	// Channel requested by remote control.
+	switchTo chan uint64
+
+	sleepTimer *conply.SleepTimer
+	notifier   *conply.Notifier
+	// Duration of fade in of the next played track, used on wake up.
+	wakeRamp time.Duration
 
 	verbose *v.Verbose
 }
@@ -53,16 +86,15 @@ type Player struct {
 func NewPlayer(verbose *v.Verbose, options map[string]interface{}) *Player {
 	ply := Player{
 		cache:  make(ChannelGroups, 0),
-		grIdx:  options["channel"].(uint64),
-		chIdx:  options["channel"].(uint64),
 		status: conply.StatusPlay,
 		ticks: map[string]<-chan time.Time{
 			"track": make(chan time.Time),
 		},
-		verbose: verbose,
+		switchTo: make(chan uint64, 1),
+		verbose:  verbose,
 	}
 
-	ply.verbose.Info(Bundle + " " + Version)
+	ply.sleepTimer = conply.NewSleepTimer(ply.sleep)
 
 	return &ply
 }
@@ -81,18 +113,12 @@ func (ply *Player) Init() error {
 	}
 	ply.verbose.Debug2("Environment is OK")
 
-	// Default hotkeys.
-	hotkeys := []*kb.Hotkey{
-		{"Pause", "sig-toggle-pause"},
-		{"Control-Shift-k", "sig-toggle-pause"},
-		{"Control-Shift-d", "sig-download"},
-	}
 	// Check (and create if needed) hotkeys config file.
 	hkPath, _ := conply.GetHKPath(Bundle)
 	ply.verbose.Debug1("Reading hotkeys config data: ", hkPath)
 	if !conply.FileExists(hkPath) {
 		ply.verbose.Debug2("Hotkeys config data not found")
-		err := conply.MarshalFile(hkPath, hotkeys, true)
+		err := conply.MarshalFile(hkPath, defaultHotkeys, true)
 		if err != nil {
 			ply.verbose.Fail("Failed attempt of create hotkeys config")
 			return err
@@ -101,17 +127,85 @@ func (ply *Player) Init() error {
 		}
 	}
 
-	// Initialize VLC player.
+	// Load favorite channels.
 	var err error
+	favPath, _ := conply.GetFavoritesPath(Bundle)
+	ply.verbose.Debug1("Reading favorites: ", favPath)
+	if ply.favs, err = conply.FavoritesFromFile(favPath); err != nil {
+		ply.verbose.Fail("Favorites reading problem")
+		return err
+	}
+
+	// Keep now playing state for remote control.
+	ply.events.Subscribe(ply.np.Listen)
+
+	// Write listening history.
+	histPath, _ := conply.GetHistoryPath(Bundle)
+	ply.events.Subscribe(conply.NewHistory(histPath).Listen)
+
+	// Submit played tracks to scrobbling services.
+	scrobbler, err := conply.LoadScrobbler(Bundle, func(err error) {
+		ply.verbose.Fail("Scrobbling failed due to error: ", err)
+	})
+	switch {
+	case err != nil:
+		ply.verbose.Fail("Scrobbling will unavailable during this session due to error: ", err)
+	case scrobbler != nil:
+		ply.events.Subscribe(scrobbler.Listen)
+		ply.verbose.Debug2("Scrobbling is enabled")
+	}
+
+	// Show desktop notifications.
+	ply.notifier, err = conply.LoadNotifier(Bundle, func(err error) {
+		ply.verbose.Debug1("Notification failed due to error: ", err)
+	})
+	switch {
+	case err != nil:
+		ply.verbose.Warning("Desktop notifications will unavailable during this session due to error: ", err)
+	case ply.notifier != nil:
+		ply.events.Subscribe(ply.notifier.Listen)
+		ply.verbose.Debug2("Desktop notifications are enabled")
+	}
+
+	// Initialize VLC player.
 	ply.verbose.Debug1("Initialize VLC")
 	if ply.vlc, err = vlc.NewVlc([]string{"--quiet", "--no-video"}); err != nil {
 		return err
 	}
 	ply.verbose.Debug2("VLC is ready")
 
+	// Restore the volume of the last session.
+	sessPath, _ := conply.GetSessionPath(Bundle)
+	vol, err := conply.VolumeFromSession(sessPath)
+	if err != nil {
+		ply.verbose.Warning("Couldn't restore the volume: ", err)
+	}
+	if err = ply.vlc.SetVolume(vol.Level); err == nil {
+		err = ply.vlc.Mute(vol.Muted)
+	}
+	if err != nil {
+		ply.verbose.Warning("Couldn't set the volume: ", err)
+	}
+	ply.emitVolume()
+
 	return nil
 }
 
+// SaveSession saves the current channel, status and volume to resume them on the next start.
+func (ply *Player) SaveSession() error {
+	session := conply.Session{
+		Bundle: Bundle,
+		Paused: ply.status == conply.StatusPause,
+		Volume: conply.Volume{Level: ply.GetVolume(), Muted: ply.IsMuted()},
+	}
+	// Channel of the saved session is kept if the channel wasn't chosen.
+	if ply.channel != nil {
+		session.Group, session.Channel = ply.group.Id, ply.chIdx
+	}
+	path, _ := conply.GetSessionPath(Bundle)
+	return session.Save(path)
+}
+
 // Release player resources.
 func (ply *Player) Release() error {
 	err := ply.vlc.Release()
@@ -120,11 +214,53 @@ func (ply *Player) Release() error {
 
 // Cleanup callback will call before finishing the work.
 func (ply *Player) Cleanup() (err error) {
+	if tui != nil {
+		_ = tui.Release()
+	}
+	if termKeys != nil {
+		_ = termKeys.Release()
+	}
 	ply.verbose.Debug1("Caught SIGTERM signal")
-	ply.verbose.Debug3("Release keybinding")
-	err = keybind.Release()
-	if err != nil {
-		return err
+	ply.finishTrack(false)
+	ply.verbose.Debug3("Save session state")
+	if err := ply.SaveSession(); err != nil {
+		ply.verbose.Fail("Couldn't save session state: ", err)
+	}
+	if keybind != nil {
+		ply.verbose.Debug3("Release keybinding")
+		if err = keybind.Release(); err != nil {
+			return err
+		}
+	}
+	if mpris != nil {
+		ply.verbose.Debug3("Release MPRIS interface")
+		if err = mpris.Release(); err != nil {
+			return err
+		}
+	}
+	if control != nil {
+		ply.verbose.Debug3("Release control socket")
+		if err = control.Release(); err != nil {
+			return err
+		}
+	}
+	if api != nil {
+		ply.verbose.Debug3("Stop HTTP API")
+		if err = api.Release(); err != nil {
+			return err
+		}
+	}
+	if ply.notifier != nil {
+		ply.verbose.Debug3("Release desktop notifications")
+		if err = ply.notifier.Release(); err != nil {
+			return err
+		}
+	}
+	if evOutput != nil {
+		ply.verbose.Debug3("Close events output")
+		if err = evOutput.Release(); err != nil {
+			return err
+		}
 	}
 	ply.verbose.Debug3("Release VLC player")
 	err = ply.Release()
@@ -161,7 +297,10 @@ func (ply *Player) Catch(signal string) error {
 			}
 		}
 	case "sig-download":
-		go func(ply *Player) {
+		if ply.info == nil {
+			break
+		}
+		go func(ply *Player, info conply.TrackInfo) {
 			err, warn := ply.Download()
 			switch {
 			case err != nil:
@@ -171,7 +310,30 @@ func (ply *Player) Catch(signal string) error {
 			default:
 				ply.verbose.Debug1("Track has been successfully downloaded")
 			}
-		}(ply)
+			if warn == nil {
+				ply.emit(conply.Event{Type: conply.EventDownload, Track: info, Err: err})
+			}
+		}(ply, *ply.info)
+	case "sig-favorite":
+		if err := ply.ToggleFavorite(); err != nil {
+			ply.verbose.Fail("Couldn't save favorites: ", err)
+		}
+	case "sig-volume-up", "sig-volume-down":
+		step := conply.VolumeStep
+		if signal == "sig-volume-down" {
+			step = -step
+		}
+		if err := ply.SetVolume(ply.GetVolume() + step); err != nil {
+			ply.verbose.Fail("Couldn't change the volume: ", err)
+		} else {
+			ply.verbose.Debug2f("Volume: %d%%", ply.GetVolume())
+		}
+	case "sig-mute":
+		if err := ply.Mute(!ply.IsMuted()); err != nil {
+			ply.verbose.Fail("Couldn't mute the player: ", err)
+		}
+	case "sig-sleep":
+		ply.notifySleep(ply.sleepTimer.Cycle())
 	}
 	return nil
 }
@@ -182,10 +344,16 @@ func (ply *Player) Play() (err error) {
 		return errors.New("undefined track, call SetTrack() first")
 	}
 	trackUrl := ply.track.GetURL()
+	if ply.status == conply.StatusPlay {
+		// Smoothly switch from the previous track.
+		_ = ply.vlc.FadeOut(conply.FadeDuration)
+	}
 	err = ply.vlc.PlayURL(trackUrl)
 	if err != nil {
 		return err
 	}
+	// Track paused or stopped at once is played as well, so polling doesn't start it again.
+	ply.prevTrackUid = ply.trackUid
 	switch {
 	case ply.status == conply.StatusPause:
 		ply.verbose.Debug3("Instantly pause new track since current status is Pause")
@@ -195,15 +363,36 @@ func (ply *Player) Play() (err error) {
 		return ply.vlc.Stop()
 	default:
 		ply.verbose.Debug3("Track URL: ", trackUrl)
-		ply.status = conply.StatusPlay
+		ply.setStatus(conply.StatusPlay)
+		fade := conply.FadeDuration
+		if ply.wakeRamp > 0 {
+			fade, ply.wakeRamp = ply.wakeRamp, 0
+		}
+		// Don't wait the buffering.
+		go func() {
+			if err := ply.vlc.FadeIn(fade); err != nil {
+				ply.verbose.Fail("Fade in failed due to error: ", err)
+			}
+		}()
 	}
-	ply.prevTrackUid = ply.trackUid
 	return
 }
 
+// setStatus changes the status and notifies listeners about it.
+func (ply *Player) setStatus(status conply.Status) {
+	if ply.status == status {
+		return
+	}
+	ply.status = status
+	ply.emit(conply.Event{Type: conply.EventStatus, Status: status})
+}
+
 // Stop playing.
 func (ply *Player) Stop() error {
-	ply.status = conply.StatusStop
+	if ply.status == conply.StatusPlay {
+		_ = ply.vlc.FadeOut(conply.FadeDuration)
+	}
+	ply.setStatus(conply.StatusStop)
 	return ply.vlc.Stop()
 }
 
@@ -212,7 +401,10 @@ func (ply *Player) Pause() error {
 	if ply.status == conply.StatusPause {
 		return nil
 	}
-	ply.status = conply.StatusPause
+	ply.setStatus(conply.StatusPause)
+	if err := ply.vlc.FadeOut(conply.FadeDuration); err != nil {
+		return err
+	}
 	return ply.vlc.Pause()
 }
 
@@ -221,8 +413,11 @@ func (ply *Player) Resume() error {
 	if ply.status == conply.StatusPlay {
 		return nil
 	}
-	ply.status = conply.StatusPlay
-	return ply.vlc.Resume()
+	ply.setStatus(conply.StatusPlay)
+	if err := ply.vlc.Resume(); err != nil {
+		return err
+	}
+	return ply.vlc.FadeIn(conply.FadeDuration)
 }
 
 // GetStatus returns current status.
@@ -230,11 +425,84 @@ func (ply *Player) GetStatus() conply.Status {
 	return ply.status
 }
 
-// Download the track.
+// SetVolume sets volume from 0 to 100.
+func (ply *Player) SetVolume(volume int) error {
+	if err := ply.vlc.SetVolume(volume); err != nil {
+		return err
+	}
+	ply.emitVolume()
+	return nil
+}
+
+// GetVolume returns volume from 0 to 100.
+func (ply *Player) GetVolume() int {
+	return ply.vlc.Volume()
+}
+
+// Mute mutes or unmutes the player.
+func (ply *Player) Mute(mute bool) error {
+	if err := ply.vlc.Mute(mute); err != nil {
+		return err
+	}
+	ply.emitVolume()
+	return nil
+}
+
+// IsMuted checks if the player is muted.
+func (ply *Player) IsMuted() bool {
+	return ply.vlc.Muted()
+}
+
+// SetSleep starts the sleep timer, zero duration turns it off.
+func (ply *Player) SetSleep(d time.Duration) {
+	ply.notifySleep(ply.sleepTimer.Set(d))
+}
+
+// notifySleep reports about the sleep timer and notifies listeners.
+func (ply *Player) notifySleep(at time.Time) {
+	if at.IsZero() {
+		ply.verbose.Info("Sleep timer is off")
+	} else {
+		ply.verbose.Infof("Sleep timer is set, playing stops at %s", at.Format("15:04"))
+	}
+	ply.emit(conply.Event{Type: conply.EventSleep, SleepAt: at})
+}
+
+// sleep fades out and stops playing when the sleep timer is over.
+// Terminal UI keeps working to resume playing, otherwise the player exits.
+func (ply *Player) sleep() {
+	ply.verbose.Info("Sleep timer is over, good night")
+	ply.emit(conply.Event{Type: conply.EventSleep})
+	if ply.status == conply.StatusPlay {
+		_ = ply.vlc.FadeOut(conply.SleepFadeDuration)
+	}
+	if tui != nil {
+		ply.setStatus(conply.StatusPause)
+		_ = ply.vlc.Pause()
+		return
+	}
+	_ = syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+}
+
+// Download the track and track the job.
 func (ply *Player) Download() (error, error) {
+	job := ply.dl.Add(ply.trackInfo())
+	err, warn := ply.download(job)
+	job.Finish(err, warn)
+	return err, warn
+}
+
+// Downloads returns download jobs, see conply.Downloader.
+func (ply *Player) Downloads() []conply.DownloadJob {
+	return ply.dl.Jobs()
+}
+
+// download downloads the track.
+func (ply *Player) download(job *conply.DownloadJob) (error, error) {
 	// Make download process safety.
 	ply.muxDl.Lock()
 	defer ply.muxDl.Unlock()
+	job.Start()
 
 	chTitle := ply.channel.Title
 
@@ -260,7 +528,7 @@ func (ply *Player) Download() (error, error) {
 	ply.verbose.Debug3f("Track is ready to download:\n * source URL: %s\n * dest: %s", url, dest)
 
 	// Download the file.
-	err = conply.FileDl(url, dest)
+	err = conply.FileDlProgress(url, dest, job.SetProgress)
 	if err != nil {
 		return err, nil
 	}
@@ -289,78 +557,164 @@ func (ply *Player) Download() (error, error) {
 	return nil, nil
 }
 
-// SetTrack sets the current track to play.
-func (ply *Player) SetTrack(track *Track) {
-	ply.track = track
+// ToggleFavorite adds the current channel to favorites or removes it from there.
+func (ply *Player) ToggleFavorite() error {
+	if ply.channel == nil {
+		return errors.New("undefined channel")
+	}
+	fav := &conply.Favorite{
+		Name:    conply.Slug(ply.channel.Title),
+		Group:   ply.group.Id,
+		Channel: ply.channel.Id,
+		Title:   ply.group.Title + "/" + ply.channel.Title,
+	}
+	added := ply.favs.Toggle(fav)
+	favPath, _ := conply.GetFavoritesPath(Bundle)
+	if err := conply.MarshalFile(favPath, ply.favs, true); err != nil {
+		return err
+	}
+	if added {
+		ply.verbose.Infof("Channel %s has been added to favorites as \"%s\"", fav.Title, fav.Name)
+	} else {
+		ply.verbose.Infof("Channel %s has been removed from favorites", fav.Title)
+	}
+	return nil
 }
 
-// RetrieveTree returns tree of groups/channels.
-func (ply *Player) RetrieveTree() error {
-	ply.cache = make(ChannelGroups, 0)
+// Catalog returns groups and channels, see conply.Navigator.
+func (ply *Player) Catalog() conply.Catalog {
+	cat := make(conply.Catalog, 0, len(ply.cache))
+	for _, g := range ply.cache {
+		cat = append(cat, catalogGroup(g))
+	}
+	return cat
+}
 
-	respGroups, err := http.Get("http://101.ru/radio-top")
-	if err != nil {
-		return err
+// LookupChannel looks for the channel by ID, title or slug, see conply.Catalog.Lookup.
+// Search is limited by the group if it's given.
+func (ply *Player) LookupChannel(query string, group *ChannelGroup) (*conply.CatalogMatch, error) {
+	if group != nil {
+		return conply.Catalog{catalogGroup(group)}.Lookup(query)
 	}
-	defer func() {
-		_ = respGroups.Body.Close()
-	}()
+	return ply.Catalog().Lookup(query)
+}
 
-	docGroups, err := goquery.NewDocumentFromReader(respGroups.Body)
-	if err != nil {
-		return err
+// catalogGroup converts cached group to the catalog one.
+func catalogGroup(g *ChannelGroup) conply.CatalogGroup {
+	group := conply.CatalogGroup{Id: g.Id, Title: g.Title, Channels: make([]conply.CatalogChannel, 0, len(g.Channels))}
+	for _, c := range g.Channels {
+		group.Channels = append(group.Channels, conply.CatalogChannel{Id: c.Id, Title: c.Title})
 	}
+	return group
+}
+
+// Favorites returns favorite channels, see conply.Navigator.
+func (ply *Player) Favorites() conply.Favorites {
+	return ply.favs
+}
 
-	var wg sync.WaitGroup
-
-	docGroups.Find("ul.channel-groups li").Each(func(i int, selection *goquery.Selection) {
-		title := strings.Trim(selection.Find("a").Text(), "\n ")
-		href, exists := selection.Find("a").Attr("href")
-		if exists && len(title) > 0 {
-			id, _ := strconv.ParseUint(path.Base(href), 0, 64)
-			ply.cache = append(ply.cache, &ChannelGroup{
-				id, title, make([]*ChannelCache, 0),
-			})
-
-			wg.Add(1)
-			go func(id uint64) {
-				defer wg.Done()
-
-				respChannels, err := http.Get(fmt.Sprintf("http://101.ru/radio-top/group/%d", id))
-				if err != nil {
-					return
-				}
-				defer func() {
-					_ = respChannels.Body.Close()
-				}()
-				docChannels, err := goquery.NewDocumentFromReader(respChannels.Body)
-				if err != nil {
-					return
-				}
-
-				docChannels.Find("div.grid a.grid__title").Each(func(i int, selection *goquery.Selection) {
-					// title := selection.Find("a").Find(".h3").Text()
-					title := selection.Find("span").Text()
-					href, exists := selection.Attr("href")
-					if exists {
-						cid, _ := strconv.ParseUint(path.Base(href), 0, 64)
-						group := ply.cache.GetGroupById(id)
-						group.Channels = append(group.Channels, &ChannelCache{
-							cid, title,
-						})
-					}
-				})
-			}(id)
+// SwitchChannel asks the playing loop to switch to the channel, see conply.Navigator.
+func (ply *Player) SwitchChannel(id uint64) error {
+	found := false
+	for _, g := range ply.cache {
+		if g.Channels.GetChannelById(id) != nil {
+			found = true
+			break
 		}
-	})
-	wg.Wait()
+	}
+	if !found {
+		return conply.ErrUnknownChannel
+	}
+	select {
+	case ply.switchTo <- id:
+		return nil
+	default:
+		return conply.ErrSwitchPending
+	}
+}
+
+// trackInfo builds bundle independent info of the current track.
+func (ply *Player) trackInfo() conply.TrackInfo {
+	about := ply.track.GetShort()
+	return conply.TrackInfo{
+		Bundle:    Bundle,
+		Channel:   ply.channel.Title,
+		ChannelId: ply.channel.Id,
+		Id:        strconv.FormatUint(ply.trackUid, 10),
+		Artist:    about.DotString("titleExecutor"),
+		Title:     about.DotString("title"),
+		Album:     about.DotString("album.albumTitle"),
+		ArtURL:    ply.track.GetCover(),
+		Length:    float64(ply.track.GetLength()),
+	}
+}
+
+// startTrack notifies listeners that the current track has started.
+func (ply *Player) startTrack() {
+	info := ply.trackInfo()
+	ply.info = &info
+	ply.emit(conply.Event{Type: conply.EventTrackStart, Track: info})
+}
 
-	// Sort tree for pretty view.
-	for _, cg := range ply.cache {
-		sort.Sort(&cg.Channels)
+// finishTrack notifies listeners that the current track has finished or skipped.
+func (ply *Player) finishTrack(skipped bool) {
+	if ply.info == nil {
+		return
+	}
+	info := *ply.info
+	ply.info = nil
+	ply.emit(conply.Event{Type: conply.EventTrackFinish, Track: info, Skipped: skipped})
+}
+
+// emitVolume notifies listeners about changed volume.
+func (ply *Player) emitVolume() {
+	ply.emit(conply.Event{Type: conply.EventVolume, Volume: ply.GetVolume(), Muted: ply.IsMuted()})
+}
+
+// emit sends the event to listeners and reports about errors.
+func (ply *Player) emit(e conply.Event) {
+	if err := ply.events.Emit(e); err != nil {
+		ply.verbose.Fail("Event handling failed due to error: ", err)
+	}
+}
+
+// SetTrack sets the current track to play.
+func (ply *Player) SetTrack(track *Track) {
+	ply.track = track
+}
+
+// LoadChannels gets tree of groups/channels from the cache or remote site if cache is invalid or expired.
+func (ply *Player) LoadChannels(noCache bool) error {
+	cacheFile, _ := conply.GetChannelsPath(Bundle)
+	ply.verbose.Debug2("Look for data in cache ", cacheFile)
+	regenRequire := !conply.FileExists(cacheFile) || conply.FileAge(cacheFile) > CacheExpire || noCache
+	if !regenRequire {
+		var err error
+		ply.verbose.Debug2("Get channels from cache")
+		if ply.cache, err = ChannelsFromCache(cacheFile); err != nil {
+			return err
+		}
+		ply.verbose.Debug3f("Groups and channels list has been retrieved from cache, total groups retrieved: %d", len(ply.cache))
+		return nil
 	}
-	sort.Sort(&ply.cache)
 
+	ply.verbose.Debug2(`Cache is invalid or expired or "--no-cache" options has applied, try to regenerate it`)
+	ply.verbose.Debug2("Looking for groups and channels in remote site")
+	var partial *PartialTreeError
+	if err := ply.RetrieveTree(); errors.As(err, &partial) {
+		// Incomplete tree isn't cached, so failed groups are retrieved again on the next start.
+		ply.verbose.Warning("Channels list is incomplete: ", err)
+	} else if err != nil {
+		return err
+	}
+	ply.verbose.Debug2f("Groups and channels list has been retrieved from remote site, total groups retrieved: %d", len(ply.cache))
+	if partial != nil {
+		ply.verbose.Debug3("Incomplete groups and channels list isn't saved in cache")
+	} else if err := conply.MarshalFile(cacheFile, ply.cache, true); err != nil {
+		ply.verbose.Fail("Writing cache error: ", err)
+	} else {
+		ply.verbose.Debug3("Groups and channels list has been saved in cache file ", cacheFile)
+	}
 	return nil
 }
 
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/koykov/conply"
+)
+
+// Serve channels as endless mp3 streams to make them playable in third-party players.
+func serveProxy(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/channel/", proxyChannel)
+	verbose.Infof("Stream proxy is listening on http://%s", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// Stream tracks of the channel one by one while the client is connected.
+func proxyChannel(w http.ResponseWriter, r *http.Request) {
+	cid, err := strconv.ParseUint(path.Base(r.URL.Path), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid channel ID", http.StatusBadRequest)
+		return
+	}
+	verbose.Debug1f("Client %s connected to channel %d", r.RemoteAddr, cid)
+	defer verbose.Debug1f("Client %s disconnected from channel %d", r.RemoteAddr, cid)
+
+	p := NewPlayer(verbose, conply.Options{})
+	p.chIdx = cid
+	w.Header().Set("Content-Type", "audio/mpeg")
+	attempts := 0
+	for {
+		deadline := time.Now()
+		if err := p.RetrieveTrack(); err != nil {
+			attempts++
+			if attempts >= 3 {
+				verbose.Failf("%d failed attempts when retrieve the track for channel %d", attempts, cid)
+				return
+			}
+			p.nextFetch = DelayAfterFail
+		} else {
+			attempts = 0
+		}
+		deadline = deadline.Add(time.Duration(p.nextFetch) * time.Second)
+
+		if p.track != nil && p.trackUid != p.prevTrackUid {
+			verbose.Debug2f("Channel %d: %s", cid, p.track.ComposeTitle())
+			if err := proxyTrack(w, p.track.GetURL()); err != nil {
+				verbose.Debug2f("Channel %d: streaming stopped due to error: %s", cid, err)
+				return
+			}
+			p.prevTrackUid = p.trackUid
+		}
+
+		// Client reads the stream in real time, so wait the rest of the track before asking the next one.
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Until(deadline)):
+		}
+	}
+}
+
+// Copy the track to the client.
+func proxyTrack(w http.ResponseWriter, url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// Number of groups scraped simultaneously.
+	ScrapeWorkers = 4
+	// Number of attempts to scrape the page.
+	ScrapeAttempts = 3
+)
+
+var (
+	// Delay before the second attempt to scrape the page, it doubles on each next attempt.
+	ScrapeRetryDelay = time.Second
+	// Site URL of scraped pages.
+	siteURL = "http://101.ru"
+)
+
+// GroupError describes why the group couldn't be scraped.
+type GroupError struct {
+	Group *ChannelGroup
+	Err   error
+}
+
+func (e *GroupError) Error() string {
+	return fmt.Sprintf("group %d (%s): %s", e.Group.Id, e.Group.Title, e.Err)
+}
+
+func (e *GroupError) Unwrap() error {
+	return e.Err
+}
+
+// PartialTreeError reports groups failed to scrape, the tree contains the other ones.
+type PartialTreeError struct {
+	Total  int
+	Failed []*GroupError
+}
+
+func (e *PartialTreeError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, ge := range e.Failed {
+		msgs = append(msgs, ge.Error())
+	}
+	return fmt.Sprintf("%d of %d groups couldn't be retrieved: %s", len(e.Failed), e.Total, strings.Join(msgs, "; "))
+}
+
+// RetrieveTree returns tree of groups/channels.
+// Groups are scraped by the pool of workers, failed pages are retried. If some groups have failed anyway the tree
+// contains the rest ones and *PartialTreeError is returned. Error is returned if no group has been retrieved.
+func (ply *Player) RetrieveTree() error {
+	ply.cache = make(ChannelGroups, 0)
+
+	docGroups, err := scrape(siteURL + "/radio-top")
+	if err != nil {
+		return err
+	}
+	docGroups.Find("ul.channel-groups li").Each(func(i int, selection *goquery.Selection) {
+		title := strings.Trim(selection.Find("a").Text(), "\n ")
+		href, exists := selection.Find("a").Attr("href")
+		if !exists || len(title) == 0 {
+			return
+		}
+		id, err := strconv.ParseUint(path.Base(href), 0, 64)
+		if err != nil {
+			ply.verbose.Debug2f("Group %s skipped due to invalid link %s", title, href)
+			return
+		}
+		ply.cache = append(ply.cache, &ChannelGroup{id, title, make(Channels, 0)})
+	})
+	if len(ply.cache) == 0 {
+		return errors.New("no groups found on the site")
+	}
+
+	// Each group is filled by the only worker, so groups don't need locking.
+	var (
+		wg     sync.WaitGroup
+		mux    sync.Mutex
+		failed []*GroupError
+		jobs   = make(chan *ChannelGroup)
+	)
+	for i := 0; i < min(ScrapeWorkers, len(ply.cache)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range jobs {
+				channels, err := ply.retrieveGroup(group.Id)
+				if err != nil {
+					mux.Lock()
+					failed = append(failed, &GroupError{group, err})
+					mux.Unlock()
+					continue
+				}
+				group.Channels = channels
+				ply.verbose.Debug3f("Group %s: %d channels retrieved", group.Title, len(channels))
+			}
+		}()
+	}
+	for _, group := range ply.cache {
+		jobs <- group
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Failed groups are dropped instead of showing them empty.
+	total := len(ply.cache)
+	if len(failed) > 0 {
+		tree := make(ChannelGroups, 0, total-len(failed))
+		for _, group := range ply.cache {
+			if len(group.Channels) > 0 {
+				tree = append(tree, group)
+			}
+		}
+		ply.cache = tree
+	}
+
+	// Sort tree for pretty view.
+	for _, cg := range ply.cache {
+		sort.Sort(&cg.Channels)
+	}
+	sort.Sort(&ply.cache)
+
+	switch {
+	case len(failed) == total:
+		return fmt.Errorf("no groups retrieved, the first error: %w", failed[0])
+	case len(failed) > 0:
+		sort.Slice(failed, func(i, j int) bool { return failed[i].Group.Id < failed[j].Group.Id })
+		return &PartialTreeError{Total: total, Failed: failed}
+	}
+	return nil
+}
+
+// Get channels of the group. Group without channels is considered as failed, since the site always fills groups.
+func (ply *Player) retrieveGroup(id uint64) (Channels, error) {
+	doc, err := scrape(fmt.Sprintf("%s/radio-top/group/%d", siteURL, id))
+	if err != nil {
+		return nil, err
+	}
+	channels := make(Channels, 0)
+	doc.Find("div.grid a.grid__title").Each(func(i int, selection *goquery.Selection) {
+		title := selection.Find("span").Text()
+		href, exists := selection.Attr("href")
+		if !exists {
+			return
+		}
+		cid, err := strconv.ParseUint(path.Base(href), 0, 64)
+		if err != nil {
+			return
+		}
+		channels = append(channels, &ChannelCache{cid, title})
+	})
+	if len(channels) == 0 {
+		return nil, errors.New("no channels found")
+	}
+	return channels, nil
+}
+
+// Get the page and parse it. Failed requests are retried with growing delay.
+func scrape(url string) (doc *goquery.Document, err error) {
+	delay := ScrapeRetryDelay
+	for i := 0; i < ScrapeAttempts; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		if doc, err = scrapeOnce(url); err == nil {
+			return doc, nil
+		}
+	}
+	return nil, err
+}
+
+func scrapeOnce(url string) (*goquery.Document, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(url + ": " + resp.Status)
+	}
+	return goquery.NewDocumentFromReader(resp.Body)
+}
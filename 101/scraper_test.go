@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	v "github.com/koykov/helpers/verbose"
+)
+
+// Site stub with three groups, pages of the failed group always respond with an error.
+// Failed pages are retried without delay.
+func newTestSite(t *testing.T, failed string) *atomic.Int32 {
+	var requests atomic.Int32
+	channels := map[string]string{
+		"/radio-top/group/1": `<a class="grid__title" href="/radio/channel/12"><span>Rock</span></a>` +
+			`<a class="grid__title" href="/radio/channel/10"><span>Classic Rock</span></a>`,
+		"/radio-top/group/2": `<a class="grid__title" href="/radio/channel/20"><span>Jazz</span></a>`,
+		"/radio-top/group/3": `<a class="grid__title" href="/radio/channel/30"><span>Pop</span></a>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch {
+		case r.URL.Path == "/radio-top":
+			_, _ = w.Write([]byte(`<ul class="channel-groups">` +
+				`<li><a href="/radio-top/group/1">Rock</a></li>` +
+				`<li><a href="/radio-top/group/2">Jazz</a></li>` +
+				`<li><a href="/radio-top/group/3">Pop</a></li>` +
+				`</ul>`))
+		case r.URL.Path == failed:
+			w.WriteHeader(http.StatusInternalServerError)
+		case len(channels[r.URL.Path]) > 0:
+			_, _ = fmt.Fprintf(w, `<div class="grid">%s</div>`, channels[r.URL.Path])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	prevURL, prevDelay := siteURL, ScrapeRetryDelay
+	siteURL, ScrapeRetryDelay = srv.URL, 0
+	t.Cleanup(func() { siteURL, ScrapeRetryDelay = prevURL, prevDelay })
+	return &requests
+}
+
+func TestRetrieveTree(t *testing.T) {
+	newTestSite(t, "")
+	ply := NewPlayer(v.NewVerbose(v.LevelInfo), map[string]interface{}{})
+	if err := ply.RetrieveTree(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ply.cache) != 3 {
+		t.Fatalf("got %d groups, want 3", len(ply.cache))
+	}
+	// Channels are sorted by ID.
+	rock := ply.cache.GetGroupById(1)
+	if rock == nil || len(rock.Channels) != 2 || rock.Channels[0].Id != 10 || rock.Channels[1].Id != 12 {
+		t.Errorf("unexpected group %+v", rock)
+	}
+}
+
+func TestRetrieveTreePartial(t *testing.T) {
+	requests := newTestSite(t, "/radio-top/group/2")
+	ply := NewPlayer(v.NewVerbose(v.LevelInfo), map[string]interface{}{})
+	err := ply.RetrieveTree()
+	var pte *PartialTreeError
+	if !errors.As(err, &pte) {
+		t.Fatalf("error %v, want *PartialTreeError", err)
+	}
+	if pte.Total != 3 || len(pte.Failed) != 1 || pte.Failed[0].Group.Id != 2 {
+		t.Errorf("unexpected error %s", pte)
+	}
+	if !strings.Contains(pte.Error(), "1 of 3 groups") {
+		t.Errorf("unexpected message %q", pte.Error())
+	}
+	// Failed group is dropped from the tree, the rest ones are kept.
+	if len(ply.cache) != 2 || ply.cache.GetGroupById(2) != nil || ply.cache.GetGroupById(3) == nil {
+		t.Errorf("unexpected tree %+v", ply.cache)
+	}
+	// Groups page, two good groups and all attempts of the failed one.
+	if n := requests.Load(); n != 3+ScrapeAttempts {
+		t.Errorf("got %d requests, want %d", n, 3+ScrapeAttempts)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/koykov/conply"
 	"github.com/koykov/jsonvector"
@@ -35,6 +36,15 @@ func (t *Track) SetAudiofile(af string) {
 	t.audiofile = af
 }
 
+// GetCover returns URL of track's cover image if it's known.
+func (t Track) GetCover() string {
+	cover := t.vec.DotString("result.short.cover.coverHTTP")
+	if len(cover) > 0 && !strings.HasPrefix(cover, "http") {
+		cover = "http://101.ru" + cover
+	}
+	return cover
+}
+
 func (t Track) GetShort() *vector.Node {
 	return t.vec.Dot("result.short")
 }
@@ -63,6 +73,16 @@ func (t *Track) GetURL() string {
 	return t.audiofile
 }
 
+// GetLength returns full duration of the track in seconds, zero if it's unknown.
+func (t Track) GetLength() uint64 {
+	st, _ := t.vec.DotUint("result.stat.startSong")
+	fs, _ := t.vec.DotUint("result.stat.finishSong")
+	if st == 0 || fs <= st {
+		return 0
+	}
+	return fs - st
+}
+
 func (t Track) GetDiff() uint64 {
 	fs, _ := t.vec.DotUint("result.stat.finishSong")
 	st, _ := t.vec.DotUint("result.stat.serverTime")
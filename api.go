@@ -0,0 +1,348 @@
+package conply
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+var (
+	ErrTokenRequired = errors.New("token is required to listen non-loopback address")
+	ErrNotSupported  = errors.New("not supported by the player")
+)
+
+// HTTP API to control the player remotely.
+//
+// Endpoints:
+//   - GET /api/status - status, the current track and supported optional commands, eg "next";
+//   - GET /api/track - the current track, 204 if nothing is playing;
+//   - GET /api/catalog - groups and channels;
+//   - POST /api/channel/{id} - switch to the channel;
+//   - POST /api/pause, /api/resume, /api/toggle, /api/next, /api/prev, /api/replay, /api/download - control the playing;
+//   - POST /api/like, /api/dislike - vote for the track;
+//   - POST /api/volume-up, /api/volume-down, /api/mute - control the volume;
+//   - POST /api/sleep - cycle the sleep timer;
+//   - POST /api/volume/{level} - set the volume from 0 to 100;
+//   - GET /api/favorites - favorite channels;
+//   - GET /api/history?limit=N - recently played tracks, newest first;
+//   - GET /api/events - WebSocket stream of player events.
+//
+// Web UI is served on other paths.
+// If the token is set each API request must have header "Authorization: Bearer <token>" or query parameter "token".
+type API struct {
+	bundle  string
+	addr    string
+	token   string
+	ply     Player
+	np      *NowPlaying
+	signals map[string]bool
+	mux     *http.ServeMux
+	srv     *http.Server
+
+	muxClients sync.Mutex
+	clients    map[chan Event]struct{}
+}
+
+// The constructor.
+// Starts to listen the address in background. Now playing state must be subscribed to player events before API.
+// Signals is a list of additional signals supported by the player, eg "sig-next".
+func NewAPI(bundle, addr, token string, ply Player, np *NowPlaying, signals ...string) (*API, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(token) == 0 && !isLoopback(host) {
+		return nil, ErrTokenRequired
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	a := API{
+		bundle:  bundle,
+		addr:    addr,
+		token:   token,
+		ply:     ply,
+		np:      np,
+		signals: make(map[string]bool, len(signals)),
+		mux:     http.NewServeMux(),
+		clients: make(map[chan Event]struct{}),
+	}
+	for _, sig := range signals {
+		a.signals[sig] = true
+	}
+	a.mux.HandleFunc("GET /api/status", a.status)
+	a.mux.HandleFunc("GET /api/track", a.track)
+	a.mux.HandleFunc("GET /api/catalog", a.catalog)
+	a.mux.HandleFunc("GET /api/favorites", a.favorites)
+	a.mux.HandleFunc("GET /api/history", a.history)
+	a.mux.HandleFunc("POST /api/channel/{id}", a.channel)
+	a.mux.HandleFunc("POST /api/volume/{level}", a.volume)
+	a.mux.HandleFunc("POST /api/{command}", a.command)
+	// Origin is checked by auth, so allow clients without it.
+	a.mux.Handle("GET /api/events", websocket.Server{Handler: a.events})
+	a.mux.Handle("GET /", webUI())
+	a.srv = &http.Server{Handler: a.auth(a.mux)}
+	go func() {
+		_ = a.srv.Serve(ln)
+	}()
+	return &a, nil
+}
+
+// Register additional handler.
+func (a *API) Handle(pattern string, handler http.Handler) {
+	a.mux.Handle(pattern, handler)
+}
+
+// Handle player event, see Listener.
+func (a *API) Listen(e Event) error {
+	a.muxClients.Lock()
+	defer a.muxClients.Unlock()
+	for c := range a.clients {
+		select {
+		case c <- e:
+		default:
+			// Client is too slow, drop the event instead of blocking the player.
+		}
+	}
+	return nil
+}
+
+// Stop the server.
+func (a *API) Release() error {
+	return a.srv.Close()
+}
+
+// Check the token and reject cross-site requests.
+// Without the token only loopback hosts are accepted to prevent DNS rebinding.
+// Static files of web UI are public, they don't contain any data.
+func (a *API) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(a.token) == 0 && !a.isLocalHost(r.Host) {
+			writeJSON(w, http.StatusForbidden, ControlResponse{Error: "invalid host"})
+			return
+		}
+		if origin := r.Header.Get("Origin"); len(origin) > 0 {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeJSON(w, http.StatusForbidden, ControlResponse{Error: "cross-origin request"})
+				return
+			}
+		}
+		if len(a.token) > 0 {
+			token := r.URL.Query().Get("token")
+			if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+				token = strings.TrimPrefix(h, "Bearer ")
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+				writeJSON(w, http.StatusUnauthorized, ControlResponse{Error: "invalid token"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *API) status(w http.ResponseWriter, _ *http.Request) {
+	resp := statusResponse(a.bundle, a.np)
+	for _, cmd := range []string{"next", "prev", "replay", "like", "dislike"} {
+		if a.signals["sig-"+cmd] {
+			resp.Commands = append(resp.Commands, cmd)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *API) track(w http.ResponseWriter, _ *http.Request) {
+	track := a.np.Track()
+	if track == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, track)
+}
+
+func (a *API) catalog(w http.ResponseWriter, _ *http.Request) {
+	nav, ok := a.ply.(Navigator)
+	if !ok {
+		writeJSON(w, http.StatusNotImplemented, ControlResponse{Error: ErrNotSupported.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, nav.Catalog())
+}
+
+func (a *API) favorites(w http.ResponseWriter, _ *http.Request) {
+	nav, ok := a.ply.(Navigator)
+	if !ok {
+		writeJSON(w, http.StatusNotImplemented, ControlResponse{Error: ErrNotSupported.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, nav.Favorites())
+}
+
+func (a *API) history(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if l := r.URL.Query().Get("limit"); len(l) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			writeJSON(w, http.StatusBadRequest, ControlResponse{Error: "invalid limit"})
+			return
+		}
+	}
+	path, _ := GetHistoryPath(a.bundle)
+	recs, err := HistoryFromFile(path)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ControlResponse{Error: err.Error()})
+		return
+	}
+	if len(recs) > limit {
+		recs = recs[len(recs)-limit:]
+	}
+	res := make(HistoryRecords, 0, len(recs))
+	for i := len(recs) - 1; i >= 0; i-- {
+		res = append(res, recs[i])
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (a *API) channel(w http.ResponseWriter, r *http.Request) {
+	nav, ok := a.ply.(Navigator)
+	if !ok {
+		writeJSON(w, http.StatusNotImplemented, ControlResponse{Error: ErrNotSupported.Error()})
+		return
+	}
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ControlResponse{Error: "invalid channel ID"})
+		return
+	}
+	switch err := nav.SwitchChannel(id); {
+	case errors.Is(err, ErrUnknownChannel):
+		writeJSON(w, http.StatusNotFound, ControlResponse{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusConflict, ControlResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, ControlResponse{Ok: true})
+	}
+}
+
+func (a *API) command(w http.ResponseWriter, r *http.Request) {
+	var sig string
+	switch status := a.ply.GetStatus(); r.PathValue("command") {
+	case "pause":
+		if status == StatusPlay {
+			sig = "sig-toggle-pause"
+		}
+	case "resume":
+		if status == StatusPause {
+			sig = "sig-toggle-pause"
+		}
+	case "toggle":
+		sig = "sig-toggle-pause"
+	case "next", "prev", "replay", "like", "dislike":
+		sig = "sig-" + r.PathValue("command")
+		if !a.signals[sig] {
+			writeJSON(w, http.StatusNotImplemented, ControlResponse{Error: ErrNotSupported.Error()})
+			return
+		}
+	case "download":
+		sig = "sig-download"
+	case "volume-up", "volume-down", "mute", "sleep":
+		sig = "sig-" + r.PathValue("command")
+	default:
+		writeJSON(w, http.StatusNotFound, ControlResponse{Error: ErrUnknownCommand.Error()})
+		return
+	}
+	if len(sig) > 0 {
+		if err := a.ply.Catch(sig); err != nil {
+			writeJSON(w, http.StatusConflict, ControlResponse{Error: err.Error()})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, ControlResponse{Ok: true})
+}
+
+func (a *API) volume(w http.ResponseWriter, r *http.Request) {
+	level, err := strconv.Atoi(r.PathValue("level"))
+	if err != nil || level < 0 || level > 100 {
+		writeJSON(w, http.StatusBadRequest, ControlResponse{Error: "invalid volume level"})
+		return
+	}
+	if err := a.ply.SetVolume(level); err != nil {
+		writeJSON(w, http.StatusConflict, ControlResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, ControlResponse{Ok: true})
+}
+
+// Stream player events to WebSocket client.
+func (a *API) events(ws *websocket.Conn) {
+	c := make(chan Event, 16)
+	a.muxClients.Lock()
+	a.clients[c] = struct{}{}
+	a.muxClients.Unlock()
+	defer func() {
+		a.muxClients.Lock()
+		delete(a.clients, c)
+		a.muxClients.Unlock()
+	}()
+
+	// Detect closed connection, incoming messages are ignored.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var msg string
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+	}()
+	for {
+		select {
+		case e := <-c:
+			if err := websocket.JSON.Send(ws, e); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// Write the value as JSON response.
+func writeJSON(w http.ResponseWriter, code int, x any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(x)
+}
+
+// Check the Host header is the listen address or a loopback host.
+func (a *API) isLocalHost(hostport string) bool {
+	if hostport == a.addr {
+		return true
+	}
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = strings.Trim(hostport, "[]")
+	}
+	return isLoopback(host)
+}
+
+// Check the host is a loopback address.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,70 @@
+package conply
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testAPI(t *testing.T, token string, signals ...string) *API {
+	a, err := NewAPI("101.ru", "127.0.0.1:0", token, &testPlayer{}, &NowPlaying{}, signals...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = a.Release() })
+	return a
+}
+
+func TestAPIHost(t *testing.T) {
+	a := testAPI(t, "")
+	for host, code := range map[string]int{
+		"localhost:8180": http.StatusOK,
+		"127.0.0.1:8180": http.StatusOK,
+		"[::1]:8180":     http.StatusOK,
+		"127.0.0.1:0":    http.StatusOK,
+		"localhost":      http.StatusOK,
+		"evil.com:8180":  http.StatusForbidden,
+		"192.168.1.10":   http.StatusForbidden,
+		"localhost.evil": http.StatusForbidden,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+		r.Host = host
+		w := httptest.NewRecorder()
+		a.srv.Handler.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Errorf("host %q: got status %d, want %d", host, w.Code, code)
+		}
+	}
+
+	// Any host is accepted with the token, eg when the API is used from the local network.
+	a = testAPI(t, "secret")
+	r := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	r.Host = "192.168.1.10:8180"
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	a.srv.Handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d with the token", w.Code)
+	}
+}
+
+func TestAPICommands(t *testing.T) {
+	for _, sig := range []string{"", "sig-next"} {
+		a := testAPI(t, "", sig)
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/api/status", nil)
+		w := httptest.NewRecorder()
+		a.srv.Handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d", w.Code)
+		}
+		var resp ControlResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		// Web UI shows Skip only if the player supports it.
+		if canSkip := len(resp.Commands) == 1 && resp.Commands[0] == "next"; canSkip != (sig == "sig-next") {
+			t.Errorf("signals %q: got commands %v", sig, resp.Commands)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package conply
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	CatalogTable = "table"
+	CatalogJSON  = "json"
+	CatalogTSV   = "tsv"
+)
+
+var (
+	ErrUnknownChannel = errors.New("unknown channel")
+	ErrSwitchPending  = errors.New("channel switching is already in progress")
+)
+
+// Catalog of channels available to play.
+type Catalog []CatalogGroup
+
+// Group of channels. Bundles without groups have the only one.
+type CatalogGroup struct {
+	Id       uint64           `json:"id"`
+	Title    string           `json:"title"`
+	Channels []CatalogChannel `json:"channels"`
+}
+
+// Channel of the catalog.
+type CatalogChannel struct {
+	Id    uint64 `json:"id"`
+	Title string `json:"title"`
+	// Short name of the channel, may be empty if the bundle doesn't provide it.
+	Slug string `json:"slug,omitempty"`
+}
+
+// Player which channels may be browsed and switched remotely.
+type Navigator interface {
+	// Get groups and channels.
+	Catalog() Catalog
+	// Get favorite channels.
+	Favorites() Favorites
+	// Switch playing to the channel.
+	SwitchChannel(id uint64) error
+}
+
+// Get channels which title or slug contains the query, case-insensitive.
+// All channels of the group are kept if the group title contains the query. Empty groups are omitted.
+func (c Catalog) Filter(query string) Catalog {
+	q := strings.ToLower(query)
+	res := make(Catalog, 0, len(c))
+	for _, g := range c {
+		if strings.Contains(strings.ToLower(g.Title), q) {
+			res = append(res, g)
+			continue
+		}
+		group := CatalogGroup{Id: g.Id, Title: g.Title, Channels: make([]CatalogChannel, 0)}
+		for _, ch := range g.Channels {
+			if strings.Contains(strings.ToLower(ch.Title), q) || strings.Contains(strings.ToLower(ch.Slug), q) {
+				group.Channels = append(group.Channels, ch)
+			}
+		}
+		if len(group.Channels) > 0 {
+			res = append(res, group)
+		}
+	}
+	return res
+}
+
+// Write the catalog to w in given format.
+// Table is aligned for humans, TSV has no header and contains one channel per line with fields
+// group ID, group title, channel ID, channel title and slug, so it's handy for rofi/dmenu and cut.
+func (c Catalog) Write(w io.Writer, format string) error {
+	switch strings.ToLower(format) {
+	case CatalogTable, "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "GROUP ID\tGROUP\tID\tCHANNEL\tSLUG")
+		if err := c.writeLines(tw); err != nil {
+			return err
+		}
+		return tw.Flush()
+	case CatalogTSV:
+		return c.writeLines(w)
+	case CatalogJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(c)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+func (c Catalog) writeLines(w io.Writer) error {
+	for _, g := range c {
+		for _, ch := range g.Channels {
+			if _, err := fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\n", g.Id, g.Title, ch.Id, ch.Title, ch.Slug); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
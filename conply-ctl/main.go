@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/koykov/conply"
+)
+
+var (
+	bundle  = flag.String("b", "", "Bundle of the player to control, eg 101.ru or xradio. May be omitted if only one player is running")
+	asJSON  = flag.Bool("json", false, "Print raw JSON response")
+	aliases = map[string]string{
+		"pause":    "sig-toggle-pause",
+		"toggle":   "sig-toggle-pause",
+		"next":     "sig-next",
+		"prev":     "sig-prev",
+		"replay":   "sig-replay",
+		"like":     "sig-like",
+		"dislike":  "sig-dislike",
+		"download": "sig-download",
+		"favorite": "sig-favorite",
+		"up":       "sig-volume-up",
+		"down":     "sig-volume-down",
+		"mute":     "sig-mute",
+		"sleep":    "sig-sleep",
+	}
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), `Usage: conply-ctl [options] <command>
+
+Commands:
+	status          Show status and the current track
+	pause, toggle   Toggle pause
+	next            Skip the track
+	prev            Return to the previous track
+	replay          Play the track from the start
+	like, dislike   Vote for the track, disliked track is skipped
+	download        Download the track
+	favorite        Toggle favorite status of the current channel
+	up, down        Change the volume
+	mute            Toggle mute
+	sleep           Cycle the sleep timer
+	sig-*           Send the signal as is
+
+Options:
+`)
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd := flag.Arg(0)
+	if sig, ok := aliases[cmd]; ok {
+		cmd = sig
+	}
+
+	path, err := socketPath(*bundle)
+	if err != nil {
+		fail(err)
+	}
+	resp, err := conply.SendControl(path, conply.ControlRequest{Command: cmd})
+	if err != nil {
+		fail(err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		_ = enc.Encode(resp)
+		return
+	}
+	if cmd == conply.ControlStatus {
+		printStatus(resp)
+	}
+}
+
+// Get socket path of the bundle or the single running player.
+func socketPath(bundle string) (string, error) {
+	if len(bundle) > 0 {
+		return conply.GetControlPath(bundle), nil
+	}
+	paths, _ := filepath.Glob(filepath.Join(conply.GetRuntimeDir(), "*.sock"))
+	switch len(paths) {
+	case 0:
+		return "", fmt.Errorf("no running players found in %s", conply.GetRuntimeDir())
+	case 1:
+		return paths[0], nil
+	default:
+		bundles := make([]string, 0, len(paths))
+		for _, p := range paths {
+			bundles = append(bundles, strings.TrimSuffix(filepath.Base(p), ".sock"))
+		}
+		return "", fmt.Errorf("several players are running (%s), choose one with -b", strings.Join(bundles, ", "))
+	}
+}
+
+// Print status in human readable form.
+func printStatus(resp *conply.ControlResponse) {
+	fmt.Printf("%s: %s\n", resp.Bundle, resp.Status)
+	switch {
+	case resp.Muted:
+		fmt.Println("Volume: muted")
+	case resp.Volume != nil:
+		fmt.Printf("Volume: %d%%\n", *resp.Volume)
+	}
+	if resp.SleepAt != nil {
+		fmt.Printf("Sleep at: %s\n", resp.SleepAt.Local().Format("15:04"))
+	}
+	if t := resp.Track; t != nil {
+		fmt.Printf("Channel: %s\n", t.Channel)
+		fmt.Printf("Track: %s - %s\n", t.Artist, t.Title)
+		fmt.Printf("Position: %s\n", duration(resp.Position))
+	}
+}
+
+// Format seconds as m:ss.
+func duration(sec float64) string {
+	s := int(sec)
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "conply-ctl:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,191 @@
+package conply
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// Status query command.
+	ControlStatus = "status"
+	// Prefix of commands passed to the player as signals.
+	ControlSigPrefix = "sig-"
+)
+
+var (
+	ErrAlreadyRunning = errors.New("another player is listening the control socket")
+	ErrUnknownCommand = errors.New("unknown command")
+)
+
+// Control request, one JSON object per line.
+type ControlRequest struct {
+	Command string `json:"command"`
+}
+
+// Control response, one JSON object per line.
+type ControlResponse struct {
+	Ok       bool       `json:"ok"`
+	Error    string     `json:"error,omitempty"`
+	Bundle   string     `json:"bundle,omitempty"`
+	Status   string     `json:"status,omitempty"`
+	Position float64    `json:"position,omitempty"`
+	Track    *TrackInfo `json:"track,omitempty"`
+	Volume   *int       `json:"volume,omitempty"`
+	Muted    bool       `json:"muted,omitempty"`
+	SleepAt  *time.Time `json:"sleep_at,omitempty"`
+	// Optional commands supported by the player, given by HTTP API only.
+	Commands []string `json:"commands,omitempty"`
+}
+
+// Control socket.
+// Accepts signals, eg {"command":"sig-next"}, and status queries {"command":"status"}
+// to control the player without X server.
+type Control struct {
+	bundle string
+	path   string
+	ply    Player
+	np     *NowPlaying
+	ln     net.Listener
+}
+
+// The constructor.
+// Creates the socket in the runtime directory and starts to serve it.
+func NewControl(bundle string, ply Player, np *NowPlaying) (*Control, error) {
+	path := GetControlPath(bundle)
+	if err := MkRuntimeDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	if FileExists(path) {
+		// Socket may be left by crashed player, remove it if nobody listens.
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = conn.Close()
+			return nil, ErrAlreadyRunning
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+
+	c := Control{
+		bundle: bundle,
+		path:   path,
+		ply:    ply,
+		np:     np,
+		ln:     ln,
+	}
+	go c.serve()
+	return &c, nil
+}
+
+// Accept connections until the socket closes.
+func (c *Control) serve() {
+	for {
+		conn, err := c.ln.Accept()
+		if err != nil {
+			return
+		}
+		go c.handle(conn)
+	}
+}
+
+// Execute requests of the connection.
+func (c *Control) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	scanner := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req ControlRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			if enc.Encode(ControlResponse{Error: err.Error()}) != nil {
+				return
+			}
+			continue
+		}
+		if enc.Encode(c.Exec(req)) != nil {
+			return
+		}
+	}
+}
+
+// Execute the request.
+func (c *Control) Exec(req ControlRequest) ControlResponse {
+	switch {
+	case req.Command == ControlStatus:
+		return statusResponse(c.bundle, c.np)
+	case strings.HasPrefix(req.Command, ControlSigPrefix):
+		if err := c.ply.Catch(req.Command); err != nil {
+			return ControlResponse{Error: err.Error()}
+		}
+		return ControlResponse{Ok: true}
+	default:
+		return ControlResponse{Error: ErrUnknownCommand.Error() + " \"" + req.Command + "\""}
+	}
+}
+
+// Build response to status query.
+func statusResponse(bundle string, np *NowPlaying) ControlResponse {
+	volume, muted := np.Volume()
+	resp := ControlResponse{
+		Ok:       true,
+		Bundle:   bundle,
+		Status:   np.Status().String(),
+		Position: np.Position().Seconds(),
+		Track:    np.Track(),
+		Volume:   &volume,
+		Muted:    muted,
+	}
+	if at := np.SleepAt(); !at.IsZero() {
+		resp.SleepAt = &at
+	}
+	return resp
+}
+
+// Close the socket and remove it.
+func (c *Control) Release() error {
+	err := c.ln.Close()
+	if FileExists(c.path) {
+		if rerr := os.Remove(c.path); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// Send the command to control socket and get the response.
+func SendControl(path string, req ControlRequest) (*ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp ControlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if !resp.Ok {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
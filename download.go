@@ -0,0 +1,120 @@
+package conply
+
+import (
+	"sync"
+	"time"
+)
+
+// Max number of finished jobs to keep.
+const DownloadsKeep = 10
+
+type DownloadState int
+
+const (
+	DownloadQueued DownloadState = iota
+	DownloadActive
+	DownloadDone
+	DownloadSkipped
+	DownloadFailed
+)
+
+// Get human readable state.
+func (s DownloadState) String() string {
+	switch s {
+	case DownloadQueued:
+		return "queued"
+	case DownloadActive:
+		return "downloading"
+	case DownloadDone:
+		return "done"
+	case DownloadSkipped:
+		return "skipped"
+	default:
+		return "failed"
+	}
+}
+
+// Download jobs of the player.
+type Downloads struct {
+	mux  sync.Mutex
+	jobs []*DownloadJob
+}
+
+// Download job of the track.
+type DownloadJob struct {
+	Track TrackInfo
+	State DownloadState
+	// Progress from 0 to 1, negative if unknown.
+	Progress float64
+	// Download error or reason of skip.
+	Err  error
+	Time time.Time
+
+	d *Downloads
+}
+
+// Register new queued job.
+func (d *Downloads) Add(track TrackInfo) *DownloadJob {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	job := &DownloadJob{Track: track, Progress: -1, Time: time.Now(), d: d}
+	d.jobs = append(d.jobs, job)
+
+	// Forget the oldest finished jobs.
+	finished := 0
+	for _, j := range d.jobs {
+		if j.State >= DownloadDone {
+			finished++
+		}
+	}
+	jobs := d.jobs[:0]
+	for _, j := range d.jobs {
+		if j.State >= DownloadDone && finished > DownloadsKeep {
+			finished--
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	d.jobs = jobs
+	return job
+}
+
+// Get copy of jobs.
+func (d *Downloads) Jobs() []DownloadJob {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	res := make([]DownloadJob, 0, len(d.jobs))
+	for _, j := range d.jobs {
+		res = append(res, DownloadJob{Track: j.Track, State: j.State, Progress: j.Progress, Err: j.Err, Time: j.Time})
+	}
+	return res
+}
+
+// Mark the job as started.
+func (j *DownloadJob) Start() {
+	j.d.mux.Lock()
+	j.State, j.Time = DownloadActive, time.Now()
+	j.d.mux.Unlock()
+}
+
+// Update progress of the job.
+func (j *DownloadJob) SetProgress(p float64) {
+	j.d.mux.Lock()
+	j.Progress = p
+	j.d.mux.Unlock()
+}
+
+// Mark the job as finished with download error or warning that it was skipped.
+func (j *DownloadJob) Finish(err, warn error) {
+	j.d.mux.Lock()
+	defer j.d.mux.Unlock()
+	j.Time = time.Now()
+	switch {
+	case err != nil:
+		j.State, j.Err = DownloadFailed, err
+	case warn != nil:
+		j.State, j.Err = DownloadSkipped, warn
+	default:
+		j.State, j.Progress = DownloadDone, 1
+	}
+}
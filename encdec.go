@@ -1,6 +1,9 @@
 package conply
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"os"
+)
 
 // Common data marshaller.
 func Marshal(data interface{}, indent bool) (string, error) {
@@ -37,6 +40,19 @@ func MarshalFile(path string, data interface{}, indent bool) error {
 	return FilePut(path, value)
 }
 
+// Marshal data to the file readable by the owner only, eg config with credentials.
+func MarshalPrivateFile(path string, data interface{}, indent bool) error {
+	value, err := Marshal(data, indent)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path, []byte(value), 0600); err != nil {
+		return err
+	}
+	// Permissions of existing file aren't changed by WriteFile.
+	return os.Chmod(path, 0600)
+}
+
 // Read file contents and unmarshal it.
 func UnmarshalFile(path string, value interface{}) error {
 	contents, err := FilePull(path)
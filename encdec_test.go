@@ -0,0 +1,33 @@
+package conply
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarshalPrivateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scrobble.json")
+	// File created before is readable by others.
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := ScrobbleConfig{ListenBrainz: ListenBrainzConfig{Enabled: true, Token: "secret"}}
+	if err := MarshalPrivateFile(path, conf, true); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("file permissions %o, want 600", perm)
+	}
+	var got ScrobbleConfig
+	if err = UnmarshalFile(path, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ListenBrainz.Token != "secret" {
+		t.Errorf("unexpected config %+v", got)
+	}
+}
@@ -1,10 +1,17 @@
 package conply
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"os/user"
+	"strconv"
 	"strings"
+	"syscall"
 )
 
+var ErrUnsafeRuntimeDir = errors.New("runtime directory must be owned by the user and have mode 0700")
+
 // Checks and prepare the environment.
 func PrepareEnv(bundle string) error {
 	paths := make(map[string]string, 3)
@@ -37,6 +44,42 @@ func GetHKPath(bundle string) (string, error) {
 	return path + PS + "hotkeys.json", err
 }
 
+// Get path to favorite channels storage.
+func GetFavoritesPath(bundle string) (string, error) {
+	path, err := GetConfigDir(bundle)
+	return path + PS + "favorites.json", err
+}
+
+// Get path to the last session state.
+func GetSessionPath(bundle string) (string, error) {
+	path, err := GetConfigDir(bundle)
+	return path + PS + "session.json", err
+}
+
+// Get path to desktop notifications config.
+func GetNotifyPath(bundle string) (string, error) {
+	path, err := GetConfigDir(bundle)
+	return path + PS + "notify.json", err
+}
+
+// Get path to listening history storage.
+func GetHistoryPath(bundle string) (string, error) {
+	path, err := GetConfigDir(bundle)
+	return path + PS + "history.jsonl", err
+}
+
+// Get path to scrobbling services config.
+func GetScrobblePath(bundle string) (string, error) {
+	path, err := GetConfigDir(bundle)
+	return path + PS + "scrobble.json", err
+}
+
+// Get path to the queue of failed scrobbles.
+func GetScrobbleQueuePath(bundle string) (string, error) {
+	path, err := GetCacheDir(bundle)
+	return path + PS + "scrobble-queue.json", err
+}
+
 // Returns absolute path to cache directory.
 func GetCacheDir(bundle string) (string, error) {
 	usr, err := user.Current()
@@ -70,3 +113,34 @@ func GetDlDir(bundle, channel string) (string, error) {
 	}
 	return strings.Join(chunks, PS), nil
 }
+
+// Returns absolute path to runtime directory for sockets and other per-session files.
+func GetRuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); len(dir) > 0 {
+		return dir + PS + "conply"
+	}
+	return os.TempDir() + PS + "conply-" + strconv.Itoa(os.Getuid())
+}
+
+// Create runtime directory and check nobody else may access it.
+// Fallback directory in /tmp may be created in advance by another user, so it's refused unless it's a real directory
+// owned by the user with mode 0700.
+func MkRuntimeDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || fi.Mode().Perm() != 0700 || !ok || int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%w: %s", ErrUnsafeRuntimeDir, dir)
+	}
+	return nil
+}
+
+// Get path to control socket of the bundle.
+func GetControlPath(bundle string) string {
+	return GetRuntimeDir() + PS + bundle + ".sock"
+}
@@ -0,0 +1,38 @@
+package conply
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMkRuntimeDir(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "conply")
+	if err := MkRuntimeDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	// Existing private directory is reused.
+	if err := MkRuntimeDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Directories accessible by others and symlinks are refused.
+	public := filepath.Join(tmp, "public")
+	if err := os.Mkdir(public, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(public, 0777); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(tmp, "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{public, link} {
+		if err := MkRuntimeDir(path); !errors.Is(err, ErrUnsafeRuntimeDir) {
+			t.Errorf("%s: got error %v, want ErrUnsafeRuntimeDir", path, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package conply
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+type EventType int
+
+const (
+	// Track has started to play.
+	EventTrackStart EventType = iota
+	// Track has finished or skipped.
+	EventTrackFinish
+	// Track download has finished with success or error.
+	EventDownload
+	// Player status has changed.
+	EventStatus
+	// Volume or mute state has changed.
+	EventVolume
+	// Sleep timer has been set, turned off or is over.
+	EventSleep
+)
+
+// Get event type name.
+func (t EventType) String() string {
+	switch t {
+	case EventTrackStart:
+		return "track_start"
+	case EventTrackFinish:
+		return "track_finish"
+	case EventDownload:
+		return "download"
+	case EventStatus:
+		return "status"
+	case EventVolume:
+		return "volume"
+	case EventSleep:
+		return "sleep"
+	default:
+		return "unknown"
+	}
+}
+
+// Player event.
+type Event struct {
+	Type  EventType
+	Time  time.Time
+	Track TrackInfo
+	// Track was skipped by user, EventTrackFinish only.
+	Skipped bool
+	// Download error, EventDownload only.
+	Err error
+	// New player status, EventStatus only.
+	Status Status
+	// New volume and mute state, EventVolume only.
+	Volume int
+	Muted  bool
+	// Time the sleep timer goes off, zero if it's off, EventSleep only.
+	SleepAt time.Time
+}
+
+// Encode the event to JSON with fields relevant to its type.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event struct {
+		Type    string     `json:"type"`
+		Time    time.Time  `json:"time"`
+		Track   *TrackInfo `json:"track,omitempty"`
+		Skipped bool       `json:"skipped,omitempty"`
+		Error   string     `json:"error,omitempty"`
+		Status  string     `json:"status,omitempty"`
+		Volume  *int       `json:"volume,omitempty"`
+		Muted   *bool      `json:"muted,omitempty"`
+		SleepAt *time.Time `json:"sleep_at,omitempty"`
+	}
+	x := event{Type: e.Type.String(), Time: e.Time}
+	switch e.Type {
+	case EventStatus:
+		x.Status = e.Status.String()
+	case EventVolume:
+		x.Volume, x.Muted = &e.Volume, &e.Muted
+	case EventSleep:
+		if !e.SleepAt.IsZero() {
+			x.SleepAt = &e.SleepAt
+		}
+	default:
+		x.Track, x.Skipped = &e.Track, e.Skipped
+		if e.Err != nil {
+			x.Error = e.Err.Error()
+		}
+	}
+	return json.Marshal(x)
+}
+
+// Event listener. Listeners are called synchronously, so long operations should be done in background.
+type Listener func(e Event) error
+
+// Events dispatcher.
+type Events struct {
+	mux       sync.RWMutex
+	listeners []Listener
+}
+
+// Register the listener.
+func (e *Events) Subscribe(l Listener) {
+	e.mux.Lock()
+	e.listeners = append(e.listeners, l)
+	e.mux.Unlock()
+}
+
+// Send the event to all listeners and collect their errors.
+func (e *Events) Emit(ev Event) error {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+	var errs []error
+	for _, l := range e.listeners {
+		if err := l(ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
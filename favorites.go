@@ -0,0 +1,109 @@
+package conply
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Favorite channel.
+type Favorite struct {
+	// Short name to launch the channel quickly.
+	Name string `json:"name"`
+	// Station key, may be empty for single station bundles.
+	Station string `json:"station,omitempty"`
+	// Group ID, may be empty if bundle doesn't use groups.
+	Group   uint64 `json:"group,omitempty"`
+	Channel uint64 `json:"channel"`
+	Title   string `json:"title"`
+}
+
+// List of favorite channels.
+type Favorites []*Favorite
+
+// Get favorite by name.
+func (f *Favorites) Get(name string) *Favorite {
+	for _, fav := range *f {
+		if fav.Name == name {
+			return fav
+		}
+	}
+	return nil
+}
+
+// Look for favorite by station and channel ID.
+func (f *Favorites) Look(station string, channel uint64) *Favorite {
+	for _, fav := range *f {
+		if fav.Station == station && fav.Channel == channel {
+			return fav
+		}
+	}
+	return nil
+}
+
+// Filter favorites of given station.
+func (f *Favorites) Station(station string) Favorites {
+	res := make(Favorites, 0)
+	for _, fav := range *f {
+		if fav.Station == station {
+			res = append(res, fav)
+		}
+	}
+	return res
+}
+
+// Add the channel to favorites or remove it if it's already there.
+// Returns true if favorite has been added.
+func (f *Favorites) Toggle(fav *Favorite) bool {
+	for i, ex := range *f {
+		if ex.Station == fav.Station && ex.Channel == fav.Channel {
+			*f = append((*f)[:i], (*f)[i+1:]...)
+			return false
+		}
+	}
+	if len(fav.Name) == 0 {
+		fav.Name = Slug(fav.Title)
+	}
+	// Keep names unique.
+	name := fav.Name
+	for i := 2; f.Get(fav.Name) != nil; i++ {
+		fav.Name = fmt.Sprintf("%s-%d", name, i)
+	}
+	*f = append(*f, fav)
+	return true
+}
+
+// Build a human readable list of a favorites.
+func (f *Favorites) PrettyPrint() string {
+	var res []string
+	for _, fav := range *f {
+		res = append(res, fmt.Sprintf("%s - %s", fav.Name, fav.Title))
+	}
+	return strings.Join(res, "\n")
+}
+
+// Load favorites from the file. Missing file means empty list.
+func FavoritesFromFile(path string) (Favorites, error) {
+	f := Favorites{}
+	if !FileExists(path) {
+		return f, nil
+	}
+	err := UnmarshalFile(path, &f)
+	return f, err
+}
+
+// Make a short lowercase name from the title, eg "Classic Rock" -> "classic-rock".
+func Slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -4,12 +4,15 @@ go 1.22
 
 require (
 	github.com/PuerkitoBio/goquery v1.9.3
+	github.com/gdamore/tcell/v2 v2.7.4
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/koykov/helpers v0.0.0-20190126203307-0f1a515b94b0
 	github.com/koykov/jsonvector v1.2.5
 	github.com/koykov/multiflag v1.0.0
 	github.com/koykov/vector v1.2.6
-	github.com/koykov/vlc v0.0.0-20190106071822-e643fdfff717
 	github.com/mikkyang/id3-go v0.0.0-20191012064224-2c6ab3bb1fbd
+	golang.org/x/net v0.29.0
+	golang.org/x/sys v0.25.0
 )
 
 require (
@@ -17,6 +20,7 @@ require (
 	github.com/BurntSushi/xgbutil v0.0.0-20190907113008-ad855c713046 // indirect
 	github.com/andybalholm/cascadia v1.3.2 // indirect
 	github.com/djimenez/iconv-go v0.0.0-20160305225143-8960e66bd3da // indirect
+	github.com/gdamore/encoding v1.0.0 // indirect
 	github.com/koykov/bitset v1.0.0 // indirect
 	github.com/koykov/bytealg v1.0.4 // indirect
 	github.com/koykov/byteconv v1.0.0 // indirect
@@ -24,5 +28,9 @@ require (
 	github.com/koykov/entry v1.0.2 // indirect
 	github.com/koykov/indirect v1.0.1 // indirect
 	github.com/koykov/openrt v0.0.0-20240411200908-3abd933415e1 // indirect
-	golang.org/x/net v0.29.0 // indirect
+	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
+	github.com/mattn/go-runewidth v0.0.15 // indirect
+	github.com/rivo/uniseg v0.4.3 // indirect
+	golang.org/x/term v0.24.0 // indirect
+	golang.org/x/text v0.18.0 // indirect
 )
@@ -78,9 +78,14 @@ func FilePull(path string) (string, error) {
 }
 
 // Download the file and report about any error.
-func FileDl(url, dest string) (err error) {
+func FileDl(url, dest string) error {
+	return FileDlProgress(url, dest, nil)
+}
+
+// Download the file and report progress from 0 to 1 if the size is known.
+func FileDlProgress(url, dest string, progress func(p float64)) (err error) {
 	var (
-		fh *os.File
+		fh   *os.File
 		resp *http.Response
 	)
 	fh, err = os.Create(dest)
@@ -88,9 +93,8 @@ func FileDl(url, dest string) (err error) {
 		return
 	}
 	defer func() {
-		err = fh.Close()
-		if err != nil {
-			return
+		if cerr := fh.Close(); err == nil {
+			err = cerr
 		}
 	}()
 
@@ -99,22 +103,44 @@ func FileDl(url, dest string) (err error) {
 		return
 	}
 	defer func() {
-		err := resp.Body.Close()
-		if err != nil {
-			return
-		}
+		_ = resp.Body.Close()
 	}()
 
-	_, err = io.Copy(fh, resp.Body)
-	if err != nil {
-		return
+	var w io.Writer = fh
+	if progress != nil && resp.ContentLength > 0 {
+		w = &progressWriter{w: fh, total: resp.ContentLength, progress: progress}
 	}
-
+	_, err = io.Copy(w, resp.Body)
 	return
 }
 
+// Writer reporting the part of written data.
+type progressWriter struct {
+	w        io.Writer
+	done     int64
+	total    int64
+	progress func(p float64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.done += int64(n)
+	w.progress(float64(w.done) / float64(w.total))
+	return n, err
+}
+
+var haltHooks []func()
+
+// Register the function to call before halt, eg to restore the terminal.
+func OnHalt(fn func()) {
+	haltHooks = append(haltHooks, fn)
+}
+
 // Send SIGTERM signal and finish working.
 func Halt(code int) error {
+	for _, fn := range haltHooks {
+		fn()
+	}
 	err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
 	os.Exit(code)
 	return err
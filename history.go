@@ -0,0 +1,228 @@
+package conply
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HistoryCSV  = "csv"
+	HistoryJSON = "json"
+)
+
+// Listening history record.
+type HistoryRecord struct {
+	Time time.Time `json:"time"`
+	TrackInfo
+	Skipped    bool `json:"skipped"`
+	Downloaded bool `json:"downloaded"`
+}
+
+// List of history records.
+type HistoryRecords []HistoryRecord
+
+// History records filter. Zero fields are ignored.
+type HistoryFilter struct {
+	From      time.Time
+	To        time.Time
+	Station   string
+	ChannelId uint64
+	// Case-insensitive part of the channel title.
+	Channel string
+}
+
+// Make a filter by time range given as strings, see ParseTime for supported formats.
+// Date without time in "to" means the end of the day. Empty strings are ignored.
+func NewHistoryFilter(from, to string) (f HistoryFilter, err error) {
+	if len(from) > 0 {
+		if f.From, err = ParseTime(from); err != nil {
+			return
+		}
+	}
+	if len(to) > 0 {
+		if f.To, err = ParseTime(to); err != nil {
+			return
+		}
+		if !strings.Contains(to, ":") {
+			f.To = f.To.AddDate(0, 0, 1)
+		}
+	}
+	return
+}
+
+// Listening history writer.
+// Listens player events, appends a record to the file when the track starts and rewrites it on download and finish,
+// so the track is in the history even if the player is killed while playing.
+type History struct {
+	path    string
+	mux     sync.Mutex
+	current *HistoryRecord
+	// Offset of the current record in the file and the file size after writing it.
+	offset, size int64
+}
+
+// The constructor.
+func NewHistory(path string) *History {
+	return &History{path: path}
+}
+
+// Handle player event, see Listener.
+func (h *History) Listen(e Event) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	switch e.Type {
+	case EventTrackStart:
+		h.current = &HistoryRecord{Time: e.Time, TrackInfo: e.Track}
+		return h.write(false)
+	case EventDownload:
+		if h.current != nil && h.current.Id == e.Track.Id && e.Err == nil {
+			h.current.Downloaded = true
+			return h.write(true)
+		}
+	case EventTrackFinish:
+		if h.current == nil || h.current.Id != e.Track.Id {
+			return nil
+		}
+		h.current.Skipped = e.Skipped
+		err := h.write(true)
+		h.current = nil
+		return err
+	}
+	return nil
+}
+
+// Write the current record to history file.
+// Update rewrites the record written before. If the file was changed meanwhile, eg by another player of the bundle,
+// the record is appended once more and the reader keeps the last version.
+func (h *History) write(update bool) error {
+	b, err := json.Marshal(h.current)
+	if err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fh.Close()
+	}()
+	end, err := fh.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if update && end == h.size {
+		if err = fh.Truncate(h.offset); err != nil {
+			return err
+		}
+		end = h.offset
+	}
+	if _, err = fh.WriteAt(append(b, '\n'), end); err != nil {
+		return err
+	}
+	h.offset, h.size = end, end+int64(len(b))+1
+	return nil
+}
+
+// Read all history records from the file. Missing file means empty history.
+// Repeated record of the track replaces the previous one, see History.write.
+func HistoryFromFile(path string) (HistoryRecords, error) {
+	recs := HistoryRecords{}
+	fh, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return recs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = fh.Close()
+	}()
+
+	type key struct {
+		time int64
+		id   string
+	}
+	index := make(map[key]int)
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec HistoryRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, err
+		}
+		k := key{rec.Time.UnixNano(), rec.Id}
+		if i, ok := index[k]; ok {
+			recs[i] = rec
+			continue
+		}
+		index[k] = len(recs)
+		recs = append(recs, rec)
+	}
+	return recs, scanner.Err()
+}
+
+// Get records matching the filter.
+func (r HistoryRecords) Filter(f HistoryFilter) HistoryRecords {
+	res := make(HistoryRecords, 0)
+	channel := strings.ToLower(f.Channel)
+	for _, rec := range r {
+		switch {
+		case !f.From.IsZero() && rec.Time.Before(f.From),
+			!f.To.IsZero() && !rec.Time.Before(f.To),
+			len(f.Station) > 0 && rec.Station != f.Station,
+			f.ChannelId > 0 && rec.ChannelId != f.ChannelId,
+			len(channel) > 0 && !strings.Contains(strings.ToLower(rec.Channel), channel):
+			continue
+		}
+		res = append(res, rec)
+	}
+	return res
+}
+
+// Write records to w in given format.
+func (r HistoryRecords) Write(w io.Writer, format string) error {
+	switch strings.ToLower(format) {
+	case HistoryCSV, "":
+		return r.writeCSV(w)
+	case HistoryJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(r)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+func (r HistoryRecords) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"time", "bundle", "station", "channel", "artist", "title", "album", "track_id", "skipped", "downloaded"})
+	for _, rec := range r {
+		_ = cw.Write([]string{
+			rec.Time.Format(time.RFC3339), rec.Bundle, rec.Station, rec.Channel, rec.Artist, rec.Title, rec.Album, rec.Id,
+			strconv.FormatBool(rec.Skipped), strconv.FormatBool(rec.Downloaded),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Parse date or date with time in local timezone.
+// Supported formats are "2006-01-02", "2006-01-02 15:04" and "2006-01-02 15:04:05".
+func ParseTime(s string) (t time.Time, err error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,151 @@
+package conply
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testHistoryTime(t *testing.T, s string) time.Time {
+	tm, err := ParseTime(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+func TestHistoryFilter(t *testing.T) {
+	recs := HistoryRecords{
+		{Time: testHistoryTime(t, "2024-05-13 23:59"), TrackInfo: TrackInfo{Id: "1", Station: "di", Channel: "Vocal Trance", ChannelId: 3}},
+		{Time: testHistoryTime(t, "2024-05-14 14:00"), TrackInfo: TrackInfo{Id: "2", Station: "di", Channel: "Deep House", ChannelId: 2}},
+		{Time: testHistoryTime(t, "2024-05-14 15:30"), TrackInfo: TrackInfo{Id: "3", Station: "jazzradio", Channel: "Bebop", ChannelId: 7}},
+		{Time: testHistoryTime(t, "2024-05-15 00:00"), TrackInfo: TrackInfo{Id: "4", Station: "di", Channel: "Vocal House", ChannelId: 4}},
+	}
+	day, err := NewHistoryFilter("2024-05-14", "2024-05-14")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		want   string
+	}{
+		{"empty", HistoryFilter{}, "1234"},
+		// Date without time in "to" means the end of the day, the range excludes its end.
+		{"day", day, "23"},
+		{"from", HistoryFilter{From: testHistoryTime(t, "2024-05-14 14:00")}, "234"},
+		{"station", HistoryFilter{Station: "di"}, "124"},
+		{"channel ID", HistoryFilter{ChannelId: 7}, "3"},
+		{"channel title", HistoryFilter{Channel: "VOCAL"}, "14"},
+		{"combined", HistoryFilter{Station: "di", Channel: "house", To: testHistoryTime(t, "2024-05-15")}, "2"},
+	}
+	for _, tt := range tests {
+		var got string
+		for _, rec := range recs.Filter(tt.filter) {
+			got += rec.Id
+		}
+		if got != tt.want {
+			t.Errorf("%s: got records %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryFilterInvalid(t *testing.T) {
+	if _, err := NewHistoryFilter("yesterday", ""); err == nil {
+		t.Error("expected error of invalid time")
+	}
+}
+
+func TestHistoryListen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := NewHistory(path)
+	start := time.Now()
+	first, second := TrackInfo{Id: "1", Title: "first"}, TrackInfo{Id: "2", Title: "second"}
+
+	// Record is written when the track starts.
+	if err := h.Listen(Event{Type: EventTrackStart, Time: start, Track: first}); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := HistoryFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].Id != "1" || recs[0].Downloaded || recs[0].Skipped {
+		t.Fatalf("unexpected records after start %+v", recs)
+	}
+
+	// Download and finish update the record instead of adding new ones.
+	events := []Event{
+		{Type: EventDownload, Track: first},
+		{Type: EventTrackFinish, Track: first, Skipped: true},
+		{Type: EventTrackStart, Time: start.Add(time.Minute), Track: second},
+		// Events of other tracks are ignored.
+		{Type: EventDownload, Track: first},
+		{Type: EventTrackFinish, Track: first},
+		{Type: EventTrackFinish, Track: second},
+	}
+	for _, e := range events {
+		if err := h.Listen(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(raw), "\n"); lines != 2 {
+		t.Errorf("file has %d lines, want 2", lines)
+	}
+	if recs, err = HistoryFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || !recs[0].Downloaded || !recs[0].Skipped || recs[1].Downloaded || recs[1].Skipped {
+		t.Errorf("unexpected records %+v", recs)
+	}
+}
+
+func TestHistoryListenShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h, other := NewHistory(path), NewHistory(path)
+	start, track := time.Now(), TrackInfo{Id: "1"}
+	_ = h.Listen(Event{Type: EventTrackStart, Time: start, Track: track})
+	// Another player of the bundle appends its record meanwhile, so the update is appended too.
+	_ = other.Listen(Event{Type: EventTrackStart, Time: start.Add(time.Second), Track: TrackInfo{Id: "2"}})
+	_ = h.Listen(Event{Type: EventTrackFinish, Track: track, Skipped: true})
+
+	recs, err := HistoryFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].Id != "1" || !recs[0].Skipped || recs[1].Id != "2" {
+		t.Errorf("unexpected records %+v", recs)
+	}
+}
+
+func TestHistoryWriteCSV(t *testing.T) {
+	tm := time.Date(2024, 5, 14, 14, 0, 0, 0, time.UTC)
+	recs := HistoryRecords{{
+		Time:       tm,
+		TrackInfo:  TrackInfo{Bundle: "xradio", Station: "di", Channel: "Vocal Trance", Artist: "Artist, Jr.", Title: "Title", Id: "42"},
+		Downloaded: true,
+	}}
+	var buf bytes.Buffer
+	if err := recs.Write(&buf, HistoryCSV); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2024-05-14T14:00:00Z", "xradio", "di", "Vocal Trance", "Artist, Jr.", "Title", "", "42", "false", "true"}
+	if len(rows) != 2 || strings.Join(rows[1], "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected rows %q", rows)
+	}
+	if err := recs.Write(&buf, "xml"); err != ErrUnknownFormat {
+		t.Errorf("error %v, want ErrUnknownFormat", err)
+	}
+}
@@ -0,0 +1,156 @@
+package conply
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LastFMAPI = "https://ws.audioscrobbler.com/2.0/"
+)
+
+// Last.fm errors which may disappear on retry: operation failed, service offline, temporary error and rate limit.
+// Other errors, eg invalid parameters or session key, are permanent.
+var lastfmTemporaryErrors = map[int]bool{8: true, 11: true, 16: true, 29: true}
+
+// Last.fm config.
+type LastFMConfig struct {
+	Enabled bool `json:"enabled"`
+	// API base URL.
+	API       string `json:"api_url"`
+	APIKey    string `json:"api_key"`
+	APISecret string `json:"api_secret"`
+	// Credentials are used once to get the session key.
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	SessionKey string `json:"session_key"`
+}
+
+// Last.fm scrobbling service.
+type LastFM struct {
+	conf LastFMConfig
+}
+
+// The constructor.
+func NewLastFM(conf LastFMConfig) *LastFM {
+	if len(conf.API) == 0 {
+		conf.API = LastFMAPI
+	}
+	return &LastFM{conf: conf}
+}
+
+// Get service name.
+func (l *LastFM) Name() string {
+	return "lastfm"
+}
+
+// Get the session key by username and password.
+func (l *LastFM) Authenticate() (string, error) {
+	var resp struct {
+		Session struct {
+			Key string `json:"key"`
+		} `json:"session"`
+	}
+	err := l.call(url.Values{
+		"method":   {"auth.getMobileSession"},
+		"username": {l.conf.Username},
+		"password": {l.conf.Password},
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Session.Key) == 0 {
+		return "", errors.New("empty session key")
+	}
+	return resp.Session.Key, nil
+}
+
+// Report the track is playing now.
+func (l *LastFM) NowPlaying(track TrackInfo) error {
+	params := l.trackParams(track)
+	params.Set("method", "track.updateNowPlaying")
+	return l.call(params, nil)
+}
+
+// Submit the track played at given time.
+func (l *LastFM) Scrobble(track TrackInfo, at time.Time) error {
+	params := l.trackParams(track)
+	params.Set("method", "track.scrobble")
+	params.Set("timestamp", strconv.FormatInt(at.Unix(), 10))
+	return l.call(params, nil)
+}
+
+func (l *LastFM) trackParams(track TrackInfo) url.Values {
+	params := url.Values{
+		"artist": {track.Artist},
+		"track":  {track.Title},
+		"sk":     {l.conf.SessionKey},
+	}
+	if len(track.Album) > 0 {
+		params.Set("album", track.Album)
+	}
+	if track.Length > 0 {
+		params.Set("duration", strconv.Itoa(int(track.Length)))
+	}
+	return params
+}
+
+// Sign and send the request and decode the response to value if it's given.
+func (l *LastFM) call(params url.Values, value interface{}) error {
+	params.Set("api_key", l.conf.APIKey)
+	params.Set("api_sig", l.sign(params))
+	params.Set("format", "json")
+
+	response, err := http.PostForm(l.conf.API, params)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	buf, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	var apiErr struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(buf, &apiErr); err == nil && apiErr.Error != 0 {
+		return &ScrobbleError{Message: apiErr.Message, Permanent: !lastfmTemporaryErrors[apiErr.Error]}
+	}
+	if response.StatusCode != http.StatusOK {
+		return httpScrobbleError(response.StatusCode, response.Status)
+	}
+	if value != nil {
+		return json.Unmarshal(buf, value)
+	}
+	return nil
+}
+
+// Build the signature: md5 of sorted params concatenated with API secret.
+func (l *LastFM) sign(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteString(params.Get(k))
+	}
+	b.WriteString(l.conf.APISecret)
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
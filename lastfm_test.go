@@ -0,0 +1,117 @@
+package conply
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+)
+
+func testLastFMServer(t *testing.T, secret string, handle func(w http.ResponseWriter, form url.Values)) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		form := r.PostForm
+		// Signature covers all params except format and the signature itself.
+		keys := make([]string, 0, len(form))
+		for k := range form {
+			if k != "format" && k != "api_sig" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var raw string
+		for _, k := range keys {
+			raw += k + form.Get(k)
+		}
+		sum := md5.Sum([]byte(raw + secret))
+		if sig := hex.EncodeToString(sum[:]); form.Get("api_sig") != sig {
+			t.Errorf("api_sig %s, want %s", form.Get("api_sig"), sig)
+		}
+		if form.Get("format") != "json" {
+			t.Errorf("format %q, want json", form.Get("format"))
+		}
+		handle(w, form)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLastFMAuthenticate(t *testing.T) {
+	srv := testLastFMServer(t, "secret", func(w http.ResponseWriter, form url.Values) {
+		if form.Get("method") != "auth.getMobileSession" || form.Get("username") != "user" || form.Get("password") != "pass" {
+			t.Errorf("unexpected form %v", form)
+		}
+		_, _ = w.Write([]byte(`{"session":{"name":"user","key":"sk1"}}`))
+	})
+	lfm := NewLastFM(LastFMConfig{API: srv.URL, APIKey: "key", APISecret: "secret", Username: "user", Password: "pass"})
+	key, err := lfm.Authenticate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "sk1" {
+		t.Errorf("session key %q, want sk1", key)
+	}
+}
+
+func TestLastFMScrobble(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	var got url.Values
+	srv := testLastFMServer(t, "secret", func(w http.ResponseWriter, form url.Values) {
+		got = form
+		_, _ = w.Write([]byte(`{"scrobbles":{"@attr":{"accepted":1,"ignored":0}}}`))
+	})
+	lfm := NewLastFM(LastFMConfig{API: srv.URL, APIKey: "key", APISecret: "secret", SessionKey: "sk1"})
+	track := TrackInfo{Artist: "Artist", Title: "Title", Album: "Album", Length: 215.5}
+	if err := lfm.Scrobble(track, at); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"method":    "track.scrobble",
+		"artist":    "Artist",
+		"track":     "Title",
+		"album":     "Album",
+		"duration":  "215",
+		"timestamp": "1700000000",
+		"sk":        "sk1",
+		"api_key":   "key",
+	}
+	for k, v := range want {
+		if got.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, got.Get(k), v)
+		}
+	}
+}
+
+func TestLastFMError(t *testing.T) {
+	tests := []struct {
+		body      string
+		message   string
+		retryable bool
+	}{
+		{`{"error":9,"message":"Invalid session key - Please re-authenticate"}`, "Invalid session key - Please re-authenticate", false},
+		{`{"error":6,"message":"Invalid parameters"}`, "Invalid parameters", false},
+		{`{"error":11,"message":"Service Offline"}`, "Service Offline", true},
+		{`{"error":29,"message":"Rate Limit Exceeded"}`, "Rate Limit Exceeded", true},
+	}
+	for _, tt := range tests {
+		srv := testLastFMServer(t, "secret", func(w http.ResponseWriter, form url.Values) {
+			// Last.fm reports errors with 403 status and JSON body.
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(tt.body))
+		})
+		lfm := NewLastFM(LastFMConfig{API: srv.URL, APIKey: "key", APISecret: "secret", SessionKey: "bad"})
+		err := lfm.NowPlaying(TrackInfo{Artist: "Artist", Title: "Title"})
+		if err == nil || err.Error() != tt.message {
+			t.Errorf("error %v, want %q", err, tt.message)
+		}
+		if isRetryable(err) != tt.retryable {
+			t.Errorf("%q is retryable %t, want %t", tt.message, isRetryable(err), tt.retryable)
+		}
+	}
+}
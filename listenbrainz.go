@@ -0,0 +1,102 @@
+package conply
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ListenBrainzAPI = "https://api.listenbrainz.org"
+)
+
+// ListenBrainz config.
+type ListenBrainzConfig struct {
+	Enabled bool `json:"enabled"`
+	// API base URL, may point to self-hosted instance.
+	API   string `json:"api_url"`
+	Token string `json:"token"`
+}
+
+// ListenBrainz scrobbling service.
+type ListenBrainz struct {
+	conf ListenBrainzConfig
+}
+
+// The constructor.
+func NewListenBrainz(conf ListenBrainzConfig) *ListenBrainz {
+	if len(conf.API) == 0 {
+		conf.API = ListenBrainzAPI
+	}
+	conf.API = strings.TrimSuffix(conf.API, "/")
+	return &ListenBrainz{conf: conf}
+}
+
+// Get service name.
+func (l *ListenBrainz) Name() string {
+	return "listenbrainz"
+}
+
+// Report the track is playing now.
+func (l *ListenBrainz) NowPlaying(track TrackInfo) error {
+	return l.submit("playing_now", track, time.Time{})
+}
+
+// Submit the track played at given time.
+func (l *ListenBrainz) Scrobble(track TrackInfo, at time.Time) error {
+	return l.submit("single", track, at)
+}
+
+func (l *ListenBrainz) submit(listenType string, track TrackInfo, at time.Time) error {
+	type metadata struct {
+		Artist string                 `json:"artist_name"`
+		Track  string                 `json:"track_name"`
+		Album  string                 `json:"release_name,omitempty"`
+		Info   map[string]interface{} `json:"additional_info"`
+	}
+	type listen struct {
+		ListenedAt int64    `json:"listened_at,omitempty"`
+		Metadata   metadata `json:"track_metadata"`
+	}
+	l0 := listen{Metadata: metadata{
+		Artist: track.Artist,
+		Track:  track.Title,
+		Album:  track.Album,
+		Info:   map[string]interface{}{"submission_client": "conply", "media_player": track.Bundle},
+	}}
+	if track.Length > 0 {
+		l0.Metadata.Info["duration_ms"] = int64(track.Length * 1000)
+	}
+	if !at.IsZero() {
+		l0.ListenedAt = at.Unix()
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"listen_type": listenType,
+		"payload":     []listen{l0},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, l.conf.API+"/1/submit-listens", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+l.conf.Token)
+	req.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		buf, _ := ioutil.ReadAll(response.Body)
+		return httpScrobbleError(response.StatusCode, response.Status+": "+string(buf))
+	}
+	return nil
+}
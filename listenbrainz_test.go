@@ -0,0 +1,101 @@
+package conply
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testListen struct {
+	ListenType string `json:"listen_type"`
+	Payload    []struct {
+		ListenedAt int64 `json:"listened_at"`
+		Metadata   struct {
+			Artist string                 `json:"artist_name"`
+			Track  string                 `json:"track_name"`
+			Album  string                 `json:"release_name"`
+			Info   map[string]interface{} `json:"additional_info"`
+		} `json:"track_metadata"`
+	} `json:"payload"`
+}
+
+func TestListenBrainzScrobble(t *testing.T) {
+	var got testListen
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/1/submit-listens" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Token tok" {
+			t.Errorf("Authorization %q, want token", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+
+	// Trailing slash of self-hosted instance URL is trimmed.
+	lb := NewListenBrainz(ListenBrainzConfig{API: srv.URL + "/", Token: "tok"})
+	track := TrackInfo{Bundle: "xradio", Artist: "Artist", Title: "Title", Album: "Album", Length: 180}
+	if err := lb.Scrobble(track, time.Unix(1700000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got.ListenType != "single" || len(got.Payload) != 1 {
+		t.Fatalf("unexpected listen %+v", got)
+	}
+	l := got.Payload[0]
+	if l.ListenedAt != 1700000000 || l.Metadata.Artist != "Artist" || l.Metadata.Track != "Title" || l.Metadata.Album != "Album" {
+		t.Errorf("unexpected listen %+v", l)
+	}
+	if l.Metadata.Info["media_player"] != "xradio" || l.Metadata.Info["duration_ms"] != float64(180000) {
+		t.Errorf("unexpected additional info %v", l.Metadata.Info)
+	}
+}
+
+func TestListenBrainzNowPlaying(t *testing.T) {
+	var got testListen
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	lb := NewListenBrainz(ListenBrainzConfig{API: srv.URL, Token: "tok"})
+	if err := lb.NowPlaying(TrackInfo{Artist: "Artist", Title: "Title"}); err != nil {
+		t.Fatal(err)
+	}
+	// Playing now listen has no time and unknown length isn't reported.
+	if got.ListenType != "playing_now" || got.Payload[0].ListenedAt != 0 {
+		t.Errorf("unexpected listen %+v", got)
+	}
+	if _, ok := got.Payload[0].Metadata.Info["duration_ms"]; ok {
+		t.Error("duration of unknown length is reported")
+	}
+}
+
+func TestListenBrainzError(t *testing.T) {
+	tests := map[int]bool{
+		http.StatusUnauthorized:       false,
+		http.StatusBadRequest:         false,
+		http.StatusTooManyRequests:    true,
+		http.StatusServiceUnavailable: true,
+	}
+	for status, retryable := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"code":401,"error":"Invalid authorization token."}`))
+		}))
+		lb := NewListenBrainz(ListenBrainzConfig{API: srv.URL, Token: "bad"})
+		err := lb.Scrobble(TrackInfo{Artist: "Artist", Title: "Title"}, time.Now())
+		srv.Close()
+		if err == nil {
+			t.Errorf("expected error of status %d", status)
+			continue
+		}
+		if isRetryable(err) != retryable {
+			t.Errorf("error of status %d is retryable %t, want %t", status, isRetryable(err), retryable)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package conply
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Max number of channels listed by ambiguous query error.
+const ambiguousLimit = 10
+
+// Match ranks, the higher the better.
+const (
+	rankNone = iota
+	rankSubsequence
+	rankSubstring
+	rankWords
+	rankPrefix
+	rankExact
+)
+
+// Channel found in the catalog.
+type CatalogMatch struct {
+	GroupId uint64
+	Group   string
+	CatalogChannel
+}
+
+// Build a human readable line of the channel.
+func (m CatalogMatch) String() string {
+	if len(m.Group) > 0 {
+		return fmt.Sprintf("%d - %s/%s", m.Id, m.Group, m.Title)
+	}
+	return fmt.Sprintf("%d - %s", m.Id, m.Title)
+}
+
+// Error of the query matched several channels equally well.
+type AmbiguousError struct {
+	Query   string
+	Matches []CatalogMatch
+}
+
+func (e *AmbiguousError) Error() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("\"%s\" matches several channels, be more specific:", e.Query))
+	for i, m := range e.Matches {
+		if i == ambiguousLimit {
+			b.WriteString(fmt.Sprintf("\n  ...and %d more", len(e.Matches)-i))
+			break
+		}
+		b.WriteString("\n  " + m.String())
+	}
+	return b.String()
+}
+
+// Look for the channel by ID, title or slug.
+// Titles are matched case-insensitive and fuzzy: exact match is preferred over prefix, words, substring and
+// subsequence ones. Group title may be mentioned in the query as well, eg "rock classic".
+// Returns ErrUnknownChannel if nothing matched and *AmbiguousError if several channels matched equally well.
+// Channel listed in several groups is matched once, in the group it matches best.
+func (c Catalog) Lookup(query string) (*CatalogMatch, error) {
+	if id, err := strconv.ParseUint(query, 10, 64); err == nil {
+		for _, g := range c {
+			for _, ch := range g.Channels {
+				if ch.Id == id {
+					return &CatalogMatch{g.Id, g.Title, ch}, nil
+				}
+			}
+		}
+		return nil, ErrUnknownChannel
+	}
+
+	q := Slug(query)
+	if len(q) == 0 {
+		return nil, ErrUnknownChannel
+	}
+	best, matches := rankNone, make([]CatalogMatch, 0)
+	for _, g := range c {
+		for _, ch := range g.Channels {
+			rank := max(
+				matchRank(q, Slug(ch.Title)),
+				matchRank(q, Slug(ch.Slug)),
+				matchRank(q, Slug(g.Title+" "+ch.Title)),
+			)
+			if rank == rankNone || rank < best {
+				continue
+			}
+			if rank > best {
+				best, matches = rank, matches[:0]
+			}
+			if slices.ContainsFunc(matches, func(m CatalogMatch) bool { return m.Id == ch.Id }) {
+				continue
+			}
+			matches = append(matches, CatalogMatch{g.Id, g.Title, ch})
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, ErrUnknownChannel
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, &AmbiguousError{Query: query, Matches: matches}
+	}
+}
+
+// Rank the match of slugged query and key.
+func matchRank(q, key string) int {
+	switch {
+	case len(key) == 0:
+		return rankNone
+	case q == key:
+		return rankExact
+	case strings.HasPrefix(key, q):
+		return rankPrefix
+	}
+	words, keyWords := strings.Split(q, "-"), strings.Split(key, "-")
+	if every(words, func(w string) bool {
+		return some(keyWords, func(kw string) bool { return strings.HasPrefix(kw, w) })
+	}) {
+		return rankWords
+	}
+	if every(words, func(w string) bool { return strings.Contains(key, w) }) {
+		return rankSubstring
+	}
+	if isSubsequence(strings.ReplaceAll(q, "-", ""), strings.ReplaceAll(key, "-", "")) {
+		return rankSubsequence
+	}
+	return rankNone
+}
+
+// Check all letters of s are present in t in the same order.
+func isSubsequence(s, t string) bool {
+	rs := []rune(s)
+	i := 0
+	for _, r := range t {
+		if i < len(rs) && rs[i] == r {
+			i++
+		}
+	}
+	return i == len(rs)
+}
+
+func every(list []string, fn func(string) bool) bool {
+	for _, s := range list {
+		if !fn(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func some(list []string, fn func(string) bool) bool {
+	for _, s := range list {
+		if fn(s) {
+			return true
+		}
+	}
+	return false
+}
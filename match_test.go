@@ -0,0 +1,90 @@
+package conply
+
+import (
+	"errors"
+	"testing"
+)
+
+var testCatalog = Catalog{
+	{Id: 1, Title: "Rock", Channels: []CatalogChannel{
+		{Id: 10, Title: "Classic Rock", Slug: "classicrock"},
+		{Id: 11, Title: "Hard Rock", Slug: "hardrock"},
+		{Id: 12, Title: "Rock", Slug: "rock"},
+	}},
+	{Id: 2, Title: "Jazz", Channels: []CatalogChannel{
+		{Id: 20, Title: "Smooth Jazz", Slug: "smoothjazz"},
+		{Id: 21, Title: "Bebop", Slug: "bebop"},
+	}},
+	// Channels listed in several groups.
+	{Id: 3, Title: "Popular", Channels: []CatalogChannel{
+		{Id: 12, Title: "Rock", Slug: "rock"},
+		{Id: 20, Title: "Smooth Jazz", Slug: "smoothjazz"},
+	}},
+}
+
+func TestMatchRank(t *testing.T) {
+	tests := []struct {
+		q, key string
+		want   int
+	}{
+		{"rock", "rock", rankExact},
+		{"rock", "rock-n-roll", rankPrefix},
+		{"roll-rock", "rock-n-roll", rankWords},
+		{"ock", "rock", rankSubstring},
+		{"rcknrl", "rock-n-roll", rankSubsequence},
+		{"jazz", "rock", rankNone},
+		{"rock", "", rankNone},
+	}
+	for _, tt := range tests {
+		if got := matchRank(tt.q, tt.key); got != tt.want {
+			t.Errorf("matchRank(%q, %q) = %d, want %d", tt.q, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogLookup(t *testing.T) {
+	tests := []struct {
+		query string
+		id    uint64
+		group string
+	}{
+		{"10", 10, "Rock"},
+		// Exact title is preferred over prefix of group and title.
+		{"rock", 12, "Rock"},
+		{"Classic", 10, "Rock"},
+		{"classicrock", 10, "Rock"},
+		{"ROCK classic", 10, "Rock"},
+		{"smth jaz", 20, "Jazz"},
+		{"popular smooth", 20, "Popular"},
+	}
+	for _, tt := range tests {
+		m, err := testCatalog.Lookup(tt.query)
+		if err != nil {
+			t.Errorf("Lookup(%q): %s", tt.query, err)
+			continue
+		}
+		if m.Id != tt.id || m.Group != tt.group {
+			t.Errorf("Lookup(%q) = %s, want %d in %s", tt.query, m, tt.id, tt.group)
+		}
+	}
+}
+
+func TestCatalogLookupUnknown(t *testing.T) {
+	for _, query := range []string{"999", "xyz", "", "--"} {
+		if _, err := testCatalog.Lookup(query); !errors.Is(err, ErrUnknownChannel) {
+			t.Errorf("Lookup(%q) error %v, want ErrUnknownChannel", query, err)
+		}
+	}
+}
+
+func TestCatalogLookupAmbiguous(t *testing.T) {
+	_, err := testCatalog.Lookup("jazz")
+	var amb *AmbiguousError
+	if !errors.As(err, &amb) {
+		t.Fatalf("error %v, want *AmbiguousError", err)
+	}
+	// Smooth Jazz is listed in "Popular" as well, but matched once.
+	if len(amb.Matches) != 2 || amb.Matches[0].Id != 20 || amb.Matches[1].Id != 21 {
+		t.Errorf("unexpected matches %v", amb.Matches)
+	}
+}
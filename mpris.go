@@ -0,0 +1,323 @@
+package conply
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+const (
+	MPRISPath        = "/org/mpris/MediaPlayer2"
+	MPRISIface       = "org.mpris.MediaPlayer2"
+	MPRISPlayerIface = "org.mpris.MediaPlayer2.Player"
+	propsIface       = "org.freedesktop.DBus.Properties"
+
+	mprisIntrospection = `<node>
+	<interface name="org.mpris.MediaPlayer2">
+		<method name="Raise"/>
+		<method name="Quit"/>
+		<property name="CanQuit" type="b" access="read"/>
+		<property name="CanRaise" type="b" access="read"/>
+		<property name="HasTrackList" type="b" access="read"/>
+		<property name="Identity" type="s" access="read"/>
+		<property name="SupportedUriSchemes" type="as" access="read"/>
+		<property name="SupportedMimeTypes" type="as" access="read"/>
+	</interface>
+	<interface name="org.mpris.MediaPlayer2.Player">
+		<method name="Next"/>
+		<method name="Previous"/>
+		<method name="Pause"/>
+		<method name="PlayPause"/>
+		<method name="Stop"/>
+		<method name="Play"/>
+		<method name="Seek"><arg direction="in" name="Offset" type="x"/></method>
+		<method name="SetPosition"><arg direction="in" name="TrackId" type="o"/><arg direction="in" name="Position" type="x"/></method>
+		<method name="OpenUri"><arg direction="in" name="Uri" type="s"/></method>
+		<signal name="Seeked"><arg name="Position" type="x"/></signal>
+		<property name="PlaybackStatus" type="s" access="read"/>
+		<property name="Rate" type="d" access="read"/>
+		<property name="Metadata" type="a{sv}" access="read"/>
+		<property name="Volume" type="d" access="readwrite"/>
+		<property name="Position" type="x" access="read"/>
+		<property name="MinimumRate" type="d" access="read"/>
+		<property name="MaximumRate" type="d" access="read"/>
+		<property name="CanGoNext" type="b" access="read"/>
+		<property name="CanGoPrevious" type="b" access="read"/>
+		<property name="CanPlay" type="b" access="read"/>
+		<property name="CanPause" type="b" access="read"/>
+		<property name="CanSeek" type="b" access="read"/>
+		<property name="CanControl" type="b" access="read"/>
+	</interface>` + introspect.IntrospectDeclarationString + `
+	<interface name="org.freedesktop.DBus.Properties">
+		<method name="Get"><arg direction="in" name="interface" type="s"/><arg direction="in" name="property" type="s"/><arg direction="out" name="value" type="v"/></method>
+		<method name="GetAll"><arg direction="in" name="interface" type="s"/><arg direction="out" name="properties" type="a{sv}"/></method>
+		<method name="Set"><arg direction="in" name="interface" type="s"/><arg direction="in" name="property" type="s"/><arg direction="in" name="value" type="v"/></method>
+		<signal name="PropertiesChanged"><arg name="interface" type="s"/><arg name="changed_properties" type="a{sv}"/><arg name="invalidated_properties" type="as"/></signal>
+	</interface>
+</node>`
+)
+
+// MPRIS exposes the player on the session bus as org.mpris.MediaPlayer2 service.
+// Control methods are mapped to player signals, see Player.Catch.
+// See https://specifications.freedesktop.org/mpris-spec/latest/ for details.
+type MPRIS struct {
+	bundle  string
+	ply     Player
+	signals map[string]bool
+	np      *NowPlaying
+	conn    *dbus.Conn
+}
+
+// The constructor.
+// Now playing state must be subscribed to player events before MPRIS.
+// Signals is a list of additional signals supported by the player, eg "sig-next".
+func NewMPRIS(bundle string, ply Player, np *NowPlaying, signals ...string) (*MPRIS, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	m := MPRIS{
+		bundle:  bundle,
+		ply:     ply,
+		signals: make(map[string]bool, len(signals)),
+		np:      np,
+		conn:    conn,
+	}
+	for _, sig := range signals {
+		m.signals[sig] = true
+	}
+
+	err = conn.ExportMethodTable(map[string]interface{}{
+		"Raise": func() *dbus.Error { return nil },
+		"Quit":  m.quit,
+	}, MPRISPath, MPRISIface)
+	if err == nil {
+		err = conn.ExportMethodTable(map[string]interface{}{
+			"Next":        m.signal("sig-next"),
+			"Previous":    m.signal("sig-prev"),
+			"Pause":       m.pause(),
+			"PlayPause":   m.signal("sig-toggle-pause"),
+			"Stop":        m.pause(),
+			"Play":        m.play(),
+			"Seek":        func(int64) *dbus.Error { return nil },
+			"SetPosition": func(dbus.ObjectPath, int64) *dbus.Error { return nil },
+			"OpenUri":     func(string) *dbus.Error { return nil },
+		}, MPRISPath, MPRISPlayerIface)
+	}
+	if err == nil {
+		err = conn.ExportMethodTable(map[string]interface{}{
+			"Get":    m.get,
+			"GetAll": m.getAll,
+			"Set":    m.set,
+		}, MPRISPath, propsIface)
+	}
+	if err == nil {
+		err = conn.Export(introspect.Introspectable(mprisIntrospection), MPRISPath, "org.freedesktop.DBus.Introspectable")
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// Bus name elements can't start with digit and contain dots, so make it safe.
+	name := MPRISIface + ".conply_" + strings.NewReplacer(".", "_", "-", "_").Replace(bundle)
+	reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+	if err == nil && reply != dbus.RequestNameReplyPrimaryOwner {
+		// Another instance is running, so use unique name.
+		reply, err = conn.RequestName(fmt.Sprintf("%s.instance%d", name, os.Getpid()), dbus.NameFlagDoNotQueue)
+	}
+	if err == nil && reply != dbus.RequestNameReplyPrimaryOwner {
+		err = fmt.Errorf("bus name %s is already taken", name)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Handle player event, see Listener.
+func (m *MPRIS) Listen(e Event) error {
+	switch e.Type {
+	case EventTrackStart:
+		return m.emit(map[string]dbus.Variant{"Metadata": dbus.MakeVariant(m.metadata())})
+	case EventStatus:
+		return m.emit(map[string]dbus.Variant{"PlaybackStatus": dbus.MakeVariant(playbackStatus(e.Status))})
+	case EventVolume:
+		return m.emit(map[string]dbus.Variant{"Volume": dbus.MakeVariant(m.volume())})
+	}
+	return nil
+}
+
+// Release the bus connection. The connection is private, so other users of the session bus aren't affected.
+func (m *MPRIS) Release() error {
+	return m.conn.Close()
+}
+
+// Make a method handler sending the signal to the player.
+func (m *MPRIS) signal(sig string) func() *dbus.Error {
+	return func() *dbus.Error {
+		if !m.signals[sig] && sig != "sig-toggle-pause" {
+			return nil
+		}
+		if err := m.ply.Catch(sig); err != nil && err != ErrMultipleCatch {
+			return dbus.MakeFailedError(err)
+		}
+		return nil
+	}
+}
+
+// Make a method handler pausing the player, paused player stays paused.
+// There is no way to restart the stopped track, so Stop is a pause as well.
+func (m *MPRIS) pause() func() *dbus.Error {
+	return m.toggleFrom(StatusPlay)
+}
+
+// Make a method handler resuming the paused player.
+func (m *MPRIS) play() func() *dbus.Error {
+	return m.toggleFrom(StatusPause)
+}
+
+// Make a method handler toggling pause only if the player has given status.
+func (m *MPRIS) toggleFrom(status Status) func() *dbus.Error {
+	toggle := m.signal("sig-toggle-pause")
+	return func() *dbus.Error {
+		if m.ply.GetStatus() != status {
+			return nil
+		}
+		return toggle()
+	}
+}
+
+// Finish the work the same way as Ctrl-C does.
+func (m *MPRIS) quit() *dbus.Error {
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (m *MPRIS) get(iface, prop string) (dbus.Variant, *dbus.Error) {
+	props, derr := m.getAll(iface)
+	if derr != nil {
+		return dbus.Variant{}, derr
+	}
+	v, ok := props[prop]
+	if !ok {
+		return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []interface{}{prop})
+	}
+	return v, nil
+}
+
+func (m *MPRIS) getAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	switch iface {
+	case MPRISIface:
+		return map[string]dbus.Variant{
+			"CanQuit":             dbus.MakeVariant(true),
+			"CanRaise":            dbus.MakeVariant(false),
+			"HasTrackList":        dbus.MakeVariant(false),
+			"Identity":            dbus.MakeVariant("conply " + m.bundle),
+			"SupportedUriSchemes": dbus.MakeVariant([]string{}),
+			"SupportedMimeTypes":  dbus.MakeVariant([]string{}),
+		}, nil
+	case MPRISPlayerIface:
+		return map[string]dbus.Variant{
+			"PlaybackStatus": dbus.MakeVariant(playbackStatus(m.ply.GetStatus())),
+			"Rate":           dbus.MakeVariant(1.0),
+			"Metadata":       dbus.MakeVariant(m.metadata()),
+			"Volume":         dbus.MakeVariant(m.volume()),
+			"Position":       dbus.MakeVariant(m.np.Position().Microseconds()),
+			"MinimumRate":    dbus.MakeVariant(1.0),
+			"MaximumRate":    dbus.MakeVariant(1.0),
+			"CanGoNext":      dbus.MakeVariant(m.signals["sig-next"]),
+			"CanGoPrevious":  dbus.MakeVariant(m.signals["sig-prev"]),
+			"CanPlay":        dbus.MakeVariant(true),
+			"CanPause":       dbus.MakeVariant(true),
+			"CanSeek":        dbus.MakeVariant(false),
+			"CanControl":     dbus.MakeVariant(true),
+		}, nil
+	default:
+		return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface", []interface{}{iface})
+	}
+}
+
+func (m *MPRIS) set(iface, prop string, value dbus.Variant) *dbus.Error {
+	if iface != MPRISPlayerIface || prop != "Volume" {
+		return dbus.NewError("org.freedesktop.DBus.Error.PropertyReadOnly", []interface{}{prop})
+	}
+	volume, ok := value.Value().(float64)
+	if !ok {
+		return dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []interface{}{"Volume must be a double"})
+	}
+	// Setting volume unmutes the player like hardware mixers do.
+	if m.ply.IsMuted() {
+		if err := m.ply.Mute(false); err != nil {
+			return dbus.MakeFailedError(err)
+		}
+	}
+	if err := m.ply.SetVolume(int(math.Round(volume * 100))); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// Get volume in MPRIS scale, muted player has zero volume.
+func (m *MPRIS) volume() float64 {
+	if m.ply.IsMuted() {
+		return 0
+	}
+	return float64(m.ply.GetVolume()) / 100
+}
+
+// Build metadata of the current track.
+func (m *MPRIS) metadata() map[string]dbus.Variant {
+	track := m.np.Track()
+	if track == nil {
+		return map[string]dbus.Variant{
+			"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(MPRISPath + "/TrackList/NoTrack")),
+		}
+	}
+	// Object path allows only [A-Za-z0-9_] chars.
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, track.Id)
+	md := map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(MPRISPath + "/track/t" + id)),
+		"xesam:title":   dbus.MakeVariant(track.Title),
+		"xesam:artist":  dbus.MakeVariant([]string{track.Artist}),
+		"xesam:album":   dbus.MakeVariant(track.Album),
+	}
+	// Length is unknown for some tracks.
+	if track.Length > 0 {
+		md["mpris:length"] = dbus.MakeVariant(int64(track.Length * 1e6))
+	}
+	if len(track.ArtURL) > 0 {
+		md["mpris:artUrl"] = dbus.MakeVariant(track.ArtURL)
+	}
+	return md
+}
+
+// Notify clients about changed properties of the player.
+func (m *MPRIS) emit(changed map[string]dbus.Variant) error {
+	return m.conn.Emit(MPRISPath, propsIface+".PropertiesChanged", MPRISPlayerIface, changed, []string{})
+}
+
+// Convert player status to MPRIS playback status.
+func playbackStatus(status Status) string {
+	switch status {
+	case StatusPlay:
+		return "Playing"
+	case StatusPause:
+		return "Paused"
+	default:
+		return "Stopped"
+	}
+}
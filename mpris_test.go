@@ -0,0 +1,267 @@
+package conply
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Player recording caught signals.
+type testPlayer struct {
+	mux    sync.Mutex
+	status Status
+	volume int
+	muted  bool
+	caught []string
+}
+
+func (p *testPlayer) Init() error              { return nil }
+func (p *testPlayer) Release() error           { return nil }
+func (p *testPlayer) Play() error              { return nil }
+func (p *testPlayer) Stop() error              { return nil }
+func (p *testPlayer) Pause() error             { return nil }
+func (p *testPlayer) Resume() error            { return nil }
+func (p *testPlayer) Download() (error, error) { return nil, nil }
+func (p *testPlayer) Cleanup() error           { return nil }
+
+func (p *testPlayer) GetStatus() Status {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.status
+}
+
+func (p *testPlayer) SetStatus(status Status) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.status = status
+}
+
+func (p *testPlayer) SetVolume(volume int) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.volume = volume
+	return nil
+}
+
+func (p *testPlayer) GetVolume() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.volume
+}
+
+func (p *testPlayer) Mute(mute bool) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.muted = mute
+	return nil
+}
+
+func (p *testPlayer) IsMuted() bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.muted
+}
+
+func (p *testPlayer) Catch(signal string) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.caught = append(p.caught, signal)
+	return nil
+}
+
+func (p *testPlayer) Caught() []string {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return append([]string(nil), p.caught...)
+}
+
+// Run private session bus for the test and connect to it.
+func testSessionBus(t *testing.T) *dbus.Conn {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon isn't installed")
+	}
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address=1")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = strings.TrimSpace(addr)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", addr)
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func testMPRIS(t *testing.T, ply Player, np *NowPlaying, signals ...string) *MPRIS {
+	m, err := NewMPRIS("101.ru", ply, np, signals...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.Release() })
+	return m
+}
+
+func TestMPRISControl(t *testing.T) {
+	conn := testSessionBus(t)
+	ply := &testPlayer{}
+	testMPRIS(t, ply, &NowPlaying{}, "sig-next")
+	obj := conn.Object(MPRISIface+".conply_101_ru", MPRISPath)
+	call := func(method string) {
+		if err := obj.Call(MPRISPlayerIface+"."+method, 0).Err; err != nil {
+			t.Fatalf("%s: %s", method, err)
+		}
+	}
+
+	call("Next")
+	// The player doesn't support returning to the previous track.
+	call("Previous")
+	call("PlayPause")
+	// Playing player keeps playing.
+	call("Play")
+	call("Pause")
+	ply.SetStatus(StatusPause)
+	// Paused player stays paused.
+	call("Pause")
+	call("Stop")
+	call("Play")
+
+	want := []string{"sig-next", "sig-toggle-pause", "sig-toggle-pause", "sig-toggle-pause"}
+	if got := ply.Caught(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("caught %v, want %v", got, want)
+	}
+}
+
+func TestMPRISProperties(t *testing.T) {
+	conn := testSessionBus(t)
+	ply := &testPlayer{status: StatusPause}
+	np := &NowPlaying{}
+	m := testMPRIS(t, ply, np, "sig-next")
+	obj := conn.Object(MPRISIface+".conply_101_ru", MPRISPath)
+	get := func(prop string) interface{} {
+		v, err := obj.GetProperty(MPRISPlayerIface + "." + prop)
+		if err != nil {
+			t.Fatalf("%s: %s", prop, err)
+		}
+		return v.Value()
+	}
+
+	if status := get("PlaybackStatus"); status != "Paused" {
+		t.Errorf("PlaybackStatus %v, want Paused", status)
+	}
+	if next, prev := get("CanGoNext"), get("CanGoPrevious"); next != true || prev != false {
+		t.Errorf("CanGoNext %v and CanGoPrevious %v, want true and false", next, prev)
+	}
+	md := get("Metadata").(map[string]dbus.Variant)
+	if id := md["mpris:trackid"].Value(); id != dbus.ObjectPath(MPRISPath+"/TrackList/NoTrack") {
+		t.Errorf("trackid %v of missing track", id)
+	}
+
+	// Clients are notified about track change.
+	if err := conn.AddMatchSignal(dbus.WithMatchInterface(propsIface), dbus.WithMatchMember("PropertiesChanged")); err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	track := TrackInfo{Id: "12:345", Artist: "Artist", Title: "Title", Album: "Album", ArtURL: "http://127.0.0.1/cover.jpg"}
+	// Now playing state is subscribed before MPRIS.
+	e := Event{Type: EventTrackStart, Time: time.Now(), Track: track}
+	if err := np.Listen(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Listen(e); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case sig := <-signals:
+		if changed := sig.Body[1].(map[string]dbus.Variant); changed["Metadata"].Value() == nil {
+			t.Errorf("unexpected changed properties %v", changed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("PropertiesChanged isn't emitted")
+	}
+
+	md = get("Metadata").(map[string]dbus.Variant)
+	// Track ID is escaped to be a valid object path.
+	if id := md["mpris:trackid"].Value(); id != dbus.ObjectPath(MPRISPath+"/track/t12_345") {
+		t.Errorf("unexpected trackid %v", id)
+	}
+	if md["xesam:title"].Value() != "Title" || md["mpris:artUrl"].Value() != track.ArtURL {
+		t.Errorf("unexpected metadata %v", md)
+	}
+	if artist := md["xesam:artist"].Value().([]string); len(artist) != 1 || artist[0] != "Artist" {
+		t.Errorf("unexpected artist %v", artist)
+	}
+	if _, ok := md["mpris:length"]; ok {
+		t.Errorf("length of track of unknown length %v", md["mpris:length"])
+	}
+}
+
+func TestMPRISVolume(t *testing.T) {
+	conn := testSessionBus(t)
+	ply := &testPlayer{volume: 80, muted: true}
+	testMPRIS(t, ply, &NowPlaying{})
+	obj := conn.Object(MPRISIface+".conply_101_ru", MPRISPath)
+
+	// Muted player has zero volume.
+	if v, err := obj.GetProperty(MPRISPlayerIface + ".Volume"); err != nil || v.Value() != 0.0 {
+		t.Errorf("Volume %v of muted player, error %v", v.Value(), err)
+	}
+	// Setting volume unmutes the player.
+	if err := obj.SetProperty(MPRISPlayerIface+".Volume", dbus.MakeVariant(0.35)); err != nil {
+		t.Fatal(err)
+	}
+	if ply.IsMuted() || ply.GetVolume() != 35 {
+		t.Errorf("volume %d, muted %t, want 35 and unmuted", ply.GetVolume(), ply.IsMuted())
+	}
+	if err := obj.SetProperty(MPRISPlayerIface+".Volume", dbus.MakeVariant("loud")); err == nil {
+		t.Error("expected error of invalid volume")
+	}
+	if err := obj.SetProperty(MPRISPlayerIface+".Rate", dbus.MakeVariant(2.0)); err == nil {
+		t.Error("expected error of read-only property")
+	}
+}
+
+func TestMPRISInstances(t *testing.T) {
+	conn := testSessionBus(t)
+	first, err := NewMPRIS("101.ru", &testPlayer{}, &NowPlaying{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another instance of the bundle gets unique name.
+	testMPRIS(t, &testPlayer{status: StatusPause}, &NowPlaying{})
+	name := fmt.Sprintf("%s.conply_101_ru.instance%d", MPRISIface, os.Getpid())
+
+	// Connections are private, so releasing one instance doesn't affect another one.
+	if err = first.Release(); err != nil {
+		t.Fatal(err)
+	}
+	v, err := conn.Object(name, MPRISPath).GetProperty(MPRISPlayerIface + ".PlaybackStatus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Value() != "Paused" {
+		t.Errorf("PlaybackStatus %v of the second instance", v.Value())
+	}
+}
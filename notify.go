@@ -0,0 +1,202 @@
+package conply
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	NotifyDest  = "org.freedesktop.Notifications"
+	NotifyPath  = "/org/freedesktop/Notifications"
+	NotifyIface = "org.freedesktop.Notifications"
+
+	// Timeout of album art downloading.
+	artTimeout = 5 * time.Second
+)
+
+// Desktop notifications config.
+type NotifyConfig struct {
+	// Notify when the track changes.
+	Track bool `json:"track"`
+	// Notify when the track has been downloaded.
+	Download bool `json:"download"`
+	// Notify when downloading has failed or skipped.
+	DownloadError bool `json:"download_error"`
+	// Time the notification is shown in milliseconds, -1 means notification server default.
+	Timeout int32 `json:"timeout"`
+}
+
+// Notifier shows desktop notifications via org.freedesktop.Notifications service.
+// Every notification replaces the previous one instead of stacking.
+// See https://specifications.freedesktop.org/notification-spec/latest/ for details.
+type Notifier struct {
+	bundle  string
+	conf    NotifyConfig
+	artDir  string
+	onError func(err error)
+	conn    *dbus.Conn
+	mux     sync.Mutex
+	// ID of the last notification to replace it.
+	id uint32
+	// Album art of the last track, removed when the next one arrives.
+	art string
+}
+
+// The constructor.
+// Album art is downloaded to artDir, onError receives errors of background notifications.
+func NewNotifier(bundle, artDir string, conf NotifyConfig, onError func(err error)) (*Notifier, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{
+		bundle:  bundle,
+		conf:    conf,
+		artDir:  artDir,
+		onError: onError,
+		conn:    conn,
+	}, nil
+}
+
+// Load notifier of the bundle.
+// Creates the config with all notifications enabled if it doesn't exists. Returns nil if all notifications are disabled.
+func LoadNotifier(bundle string, onError func(err error)) (*Notifier, error) {
+	path, _ := GetNotifyPath(bundle)
+	conf := NotifyConfig{Track: true, Download: true, DownloadError: true, Timeout: -1}
+	if !FileExists(path) {
+		if err := MarshalFile(path, conf, true); err != nil {
+			return nil, err
+		}
+	} else if err := UnmarshalFile(path, &conf); err != nil {
+		return nil, err
+	}
+	if !conf.Track && !conf.Download && !conf.DownloadError {
+		return nil, nil
+	}
+	artDir, _ := GetCacheDir(bundle)
+	return NewNotifier(bundle, artDir, conf, onError)
+}
+
+// Handle player event, see Listener.
+// Notifications are sent in background since notification server may respond slowly.
+func (n *Notifier) Listen(e Event) error {
+	switch {
+	case e.Type == EventTrackStart && n.conf.Track:
+		go n.track(e.Track)
+	case e.Type == EventDownload && e.Err == nil && n.conf.Download:
+		go n.notify("Downloaded", trackTitle(e.Track), "", false, false)
+	case e.Type == EventDownload && e.Err != nil && n.conf.DownloadError:
+		go n.notify("Download failed", trackTitle(e.Track)+"\n"+e.Err.Error(), "", true, false)
+	}
+	return nil
+}
+
+// Release the session bus connection.
+func (n *Notifier) Release() error {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if len(n.art) > 0 {
+		_ = os.Remove(n.art)
+	}
+	return n.conn.Close()
+}
+
+// Notify about the track with its album art.
+func (n *Notifier) track(track TrackInfo) {
+	body := track.Artist
+	if len(track.Album) > 0 {
+		body += "\n" + track.Album
+	}
+	icon := ""
+	if len(track.ArtURL) > 0 {
+		var err error
+		if icon, err = n.fetchArt(track.ArtURL); err != nil {
+			// Notification without art is still useful.
+			n.onError(err)
+		}
+	}
+	n.notify(track.Title, body, icon, false, true)
+}
+
+// Send the notification replacing the previous one.
+// Transient notifications aren't kept in notification server history.
+func (n *Notifier) notify(summary, body, icon string, critical, transient bool) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	hints := map[string]dbus.Variant{
+		"transient": dbus.MakeVariant(transient),
+	}
+	if critical {
+		hints["urgency"] = dbus.MakeVariant(byte(2))
+	}
+	if len(icon) > 0 {
+		hints["image-path"] = dbus.MakeVariant(icon)
+	}
+	obj := n.conn.Object(NotifyDest, NotifyPath)
+	call := obj.Call(NotifyIface+".Notify", 0, n.bundle, n.id, icon, summary, escapeMarkup(body), []string{}, hints, n.conf.Timeout)
+	if call.Err != nil {
+		n.onError(call.Err)
+		return
+	}
+	_ = call.Store(&n.id)
+}
+
+// Download album art to the file, notification servers don't load remote images.
+// Every track gets own file, since servers may cache images by path.
+func (n *Notifier) fetchArt(url string) (string, error) {
+	sum := md5.Sum([]byte(url))
+	path := filepath.Join(n.artDir, "art-"+hex.EncodeToString(sum[:]))
+	n.mux.Lock()
+	prev := n.art
+	n.mux.Unlock()
+	if path == prev {
+		return path, nil
+	}
+
+	client := http.Client{Timeout: artTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("album art: " + resp.Status)
+	}
+	fh, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(fh, resp.Body); err != nil {
+		_ = fh.Close()
+		_ = os.Remove(path)
+		return "", err
+	}
+	if err = fh.Close(); err != nil {
+		return "", err
+	}
+
+	n.mux.Lock()
+	if len(n.art) > 0 {
+		_ = os.Remove(n.art)
+	}
+	n.art = path
+	n.mux.Unlock()
+	return path, nil
+}
+
+// Notification body may contain simple markup, so escape special characters.
+func escapeMarkup(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
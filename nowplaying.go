@@ -0,0 +1,92 @@
+package conply
+
+import (
+	"sync"
+	"time"
+)
+
+// Now playing state of the player.
+// Listens player events and keeps the current track, status, position, volume and sleep timer.
+type NowPlaying struct {
+	mux       sync.RWMutex
+	track     *TrackInfo
+	status    Status
+	played    time.Duration
+	resumedAt time.Time
+	volume    int
+	muted     bool
+	sleepAt   time.Time
+}
+
+// Handle player event, see Listener.
+func (n *NowPlaying) Listen(e Event) error {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	switch e.Type {
+	case EventTrackStart:
+		track := e.Track
+		n.track, n.played, n.resumedAt = &track, 0, time.Time{}
+		if n.status == StatusPlay {
+			n.resumedAt = e.Time
+		}
+	case EventTrackFinish:
+		n.track = nil
+	case EventStatus:
+		if !n.resumedAt.IsZero() {
+			n.played += e.Time.Sub(n.resumedAt)
+			n.resumedAt = time.Time{}
+		}
+		if e.Status == StatusPlay {
+			n.resumedAt = e.Time
+		}
+		n.status = e.Status
+	case EventVolume:
+		n.volume, n.muted = e.Volume, e.Muted
+	case EventSleep:
+		n.sleepAt = e.SleepAt
+	}
+	return nil
+}
+
+// Get the current track, nil if nothing is playing.
+func (n *NowPlaying) Track() *TrackInfo {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	if n.track == nil {
+		return nil
+	}
+	track := *n.track
+	return &track
+}
+
+// Get the current status.
+func (n *NowPlaying) Status() Status {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.status
+}
+
+// Get the time the current track has been played.
+func (n *NowPlaying) Position() time.Duration {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	played := n.played
+	if !n.resumedAt.IsZero() {
+		played += time.Since(n.resumedAt)
+	}
+	return played
+}
+
+// Get volume and mute state.
+func (n *NowPlaying) Volume() (int, bool) {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.volume, n.muted
+}
+
+// Get the time the sleep timer goes off, zero if it's off.
+func (n *NowPlaying) SleepAt() time.Time {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.sleepAt
+}
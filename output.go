@@ -0,0 +1,145 @@
+package conply
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	OutputJSONL = "jsonl"
+	OutputText  = "text"
+)
+
+// Machine-readable output of player events for status bars and scripts.
+//
+// JSONL format writes every event as a JSON object per line, see Event.MarshalJSON.
+// Text format writes "Artist - Title" of the current track and an empty line when playing stops.
+// Regular file in text format is rewritten on every track, so it always holds the current track only,
+// other destinations get a line per track.
+//
+// Destination may be stdout (empty path or "-"), a regular file or a FIFO.
+// Human-readable log goes to stderr if the output is written to stdout.
+// Events are dropped while FIFO has no reader, so the player never blocks on it.
+type Output struct {
+	format string
+	path   string
+	mux    sync.Mutex
+	w      io.WriteCloser
+	stdout *os.File
+	fifo   bool
+	text   bool
+}
+
+// The constructor.
+func NewOutput(format, path string) (*Output, error) {
+	o := Output{format: strings.ToLower(format), path: path}
+	if o.format != OutputJSONL && o.format != OutputText {
+		return nil, ErrUnknownFormat
+	}
+	if len(path) == 0 || path == "-" {
+		o.w, o.stdout, os.Stdout = os.Stdout, os.Stdout, os.Stderr
+		return &o, nil
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.Mode()&os.ModeNamedPipe != 0:
+		// FIFO opens on the first event having a reader.
+		o.fifo = true
+	case o.format == OutputText:
+		// File is rewritten on every track.
+		o.text = true
+		err = o.put("")
+	default:
+		o.w, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Handle player event, see Listener.
+func (o *Output) Listen(e Event) error {
+	if o.format == OutputJSONL {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return o.write(append(b, '\n'))
+	}
+
+	var line string
+	switch {
+	case e.Type == EventTrackStart:
+		line = trackTitle(e.Track)
+	case e.Type == EventStatus && e.Status == StatusStop:
+		// Nothing is playing.
+	default:
+		return nil
+	}
+	if o.text {
+		return o.put(line)
+	}
+	return o.write([]byte(line + "\n"))
+}
+
+// Write the data to the destination.
+func (o *Output) write(b []byte) error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.fifo && o.w == nil {
+		fh, err := os.OpenFile(o.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			// Nobody reads the FIFO.
+			return nil
+		}
+		o.w = fh
+	}
+	if o.w == nil {
+		return nil
+	}
+	if _, err := o.w.Write(b); err != nil {
+		if o.fifo {
+			// Reader has gone, wait for the next one.
+			_ = o.w.Close()
+			o.w = nil
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Replace the file contents with the line. File is replaced atomically, so readers never see it partially written.
+func (o *Output) put(line string) error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(line+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path)
+}
+
+// Close the destination. Text file is cleared since nothing is playing anymore.
+func (o *Output) Release() error {
+	if o.text {
+		return o.put("")
+	}
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.stdout != nil {
+		os.Stdout = o.stdout
+		return nil
+	}
+	if o.w != nil {
+		err := o.w.Close()
+		o.w = nil
+		return err
+	}
+	return nil
+}
@@ -19,8 +19,21 @@ const (
 
 var (
 	ErrMultipleCatch = errors.New("multiple key press caught")
+	ErrUnknownFormat = errors.New("unknown format")
 )
 
+// Get human readable status.
+func (s Status) String() string {
+	switch s {
+	case StatusPlay:
+		return "playing"
+	case StatusPause:
+		return "paused"
+	default:
+		return "stopped"
+	}
+}
+
 // The player interface.
 type Player interface {
 	Init() error
@@ -30,6 +43,11 @@ type Player interface {
 	Pause() error
 	Resume() error
 	GetStatus() Status
+	// Set volume from 0 to 100.
+	SetVolume(volume int) error
+	GetVolume() int
+	Mute(mute bool) error
+	IsMuted() bool
 	Download() (error, error)
 	Catch(signal string) error
 	Cleanup() error
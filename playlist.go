@@ -0,0 +1,109 @@
+package conply
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	PlaylistM3U  = "m3u"
+	PlaylistPLS  = "pls"
+	PlaylistXSPF = "xspf"
+)
+
+// Playlist of playable stream URLs.
+type Playlist struct {
+	Title string
+	Items []PlaylistItem
+}
+
+// Playlist item.
+type PlaylistItem struct {
+	Title string
+	URL   string
+}
+
+// Add the stream to the playlist.
+func (p *Playlist) Add(title, url string) {
+	p.Items = append(p.Items, PlaylistItem{Title: title, URL: url})
+}
+
+// Write the playlist to w in given format.
+func (p *Playlist) Write(w io.Writer, format string) error {
+	switch strings.ToLower(format) {
+	case PlaylistM3U, "m3u8":
+		return p.writeM3U(w)
+	case PlaylistPLS:
+		return p.writePLS(w)
+	case PlaylistXSPF:
+		return p.writeXSPF(w)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+func (p *Playlist) writeM3U(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "#EXTM3U"); err != nil {
+		return err
+	}
+	for _, item := range p.Items {
+		if _, err := fmt.Fprintf(w, "#EXTINF:-1,%s\n%s\n", item.Title, item.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Playlist) writePLS(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "[playlist]"); err != nil {
+		return err
+	}
+	for i, item := range p.Items {
+		if _, err := fmt.Fprintf(w, "File%d=%s\nTitle%d=%s\nLength%d=-1\n", i+1, item.URL, i+1, item.Title, i+1); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "NumberOfEntries=%d\nVersion=2\n", len(p.Items))
+	return err
+}
+
+func (p *Playlist) writeXSPF(w io.Writer) error {
+	type track struct {
+		Location string `xml:"location"`
+		Title    string `xml:"title"`
+	}
+	type playlist struct {
+		XMLName xml.Name `xml:"playlist"`
+		Version string   `xml:"version,attr"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Title   string   `xml:"title,omitempty"`
+		Tracks  []track  `xml:"trackList>track"`
+	}
+	x := playlist{Version: "1", Xmlns: "http://xspf.org/ns/0/", Title: p.Title}
+	for _, item := range p.Items {
+		x.Tracks = append(x.Tracks, track{Location: item.URL, Title: item.Title})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(x); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Get playlist format by file extension. Returns M3U if extension is unknown.
+func PlaylistFormat(path string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")); ext {
+	case PlaylistPLS, PlaylistXSPF:
+		return ext
+	default:
+		return PlaylistM3U
+	}
+}
@@ -0,0 +1,90 @@
+package conply
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"testing"
+)
+
+func testPlaylist() *Playlist {
+	p := Playlist{Title: "101.ru"}
+	p.Add("Rock", "http://127.0.0.1:8101/channel/12")
+	p.Add("Jazz & Blues", "http://127.0.0.1:8101/channel/20")
+	return &p
+}
+
+func TestPlaylistM3U(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testPlaylist().Write(&buf, "M3U8"); err != nil {
+		t.Fatal(err)
+	}
+	want := "#EXTM3U\n" +
+		"#EXTINF:-1,Rock\nhttp://127.0.0.1:8101/channel/12\n" +
+		"#EXTINF:-1,Jazz & Blues\nhttp://127.0.0.1:8101/channel/20\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestPlaylistPLS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testPlaylist().Write(&buf, PlaylistPLS); err != nil {
+		t.Fatal(err)
+	}
+	want := "[playlist]\n" +
+		"File1=http://127.0.0.1:8101/channel/12\nTitle1=Rock\nLength1=-1\n" +
+		"File2=http://127.0.0.1:8101/channel/20\nTitle2=Jazz & Blues\nLength2=-1\n" +
+		"NumberOfEntries=2\nVersion=2\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestPlaylistXSPF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testPlaylist().Write(&buf, PlaylistXSPF); err != nil {
+		t.Fatal(err)
+	}
+	var x struct {
+		XMLName xml.Name `xml:"http://xspf.org/ns/0/ playlist"`
+		Version string   `xml:"version,attr"`
+		Title   string   `xml:"title"`
+		Tracks  []struct {
+			Location string `xml:"location"`
+			Title    string `xml:"title"`
+		} `xml:"trackList>track"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &x); err != nil {
+		t.Fatal(err)
+	}
+	if x.Version != "1" || x.Title != "101.ru" || len(x.Tracks) != 2 {
+		t.Fatalf("unexpected playlist %+v", x)
+	}
+	// Special characters are escaped.
+	if x.Tracks[1].Title != "Jazz & Blues" || x.Tracks[1].Location != "http://127.0.0.1:8101/channel/20" {
+		t.Errorf("unexpected track %+v", x.Tracks[1])
+	}
+}
+
+func TestPlaylistUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testPlaylist().Write(&buf, "wpl"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("error %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestPlaylistFormat(t *testing.T) {
+	tests := map[string]string{
+		"radio.m3u":       PlaylistM3U,
+		"radio.PLS":       PlaylistPLS,
+		"/tmp/radio.xspf": PlaylistXSPF,
+		"radio":           PlaylistM3U,
+		"radio.txt":       PlaylistM3U,
+	}
+	for path, want := range tests {
+		if got := PlaylistFormat(path); got != want {
+			t.Errorf("PlaylistFormat(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
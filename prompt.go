@@ -0,0 +1,52 @@
+package conply
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Read a line from stdin after the prompt, eg email.
+// Stdin is read byte by byte to leave the rest of input to other readers, eg ReadSecret.
+func ReadLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+	return readLine()
+}
+
+// Read a line from the terminal without echo, eg password.
+func ReadSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return "", ErrNotTerminal
+	}
+	noEcho := *state
+	noEcho.Lflag &^= unix.ECHO
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &noEcho); err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = unix.IoctlSetTermios(fd, ioctlSetTermios, state)
+		// Enter isn't echoed as well.
+		fmt.Println()
+	}()
+
+	fmt.Print(prompt)
+	return readLine()
+}
+
+func readLine() (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		if _, err := os.Stdin.Read(buf); err != nil {
+			return "", err
+		}
+		if buf[0] == '\n' {
+			return strings.TrimSuffix(string(line), "\r"), nil
+		}
+		line = append(line, buf[0])
+	}
+}
@@ -0,0 +1,271 @@
+package conply
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// Tracks shorter than this can't be scrobbled.
+	ScrobbleMinLength = 30 * time.Second
+	// Track is scrobbled when it has been played at least half or this time.
+	ScrobbleMinPlayed = 4 * time.Minute
+	// Limits of the queue of failed scrobbles, the oldest ones are dropped. Last.fm ignores scrobbles older than
+	// two weeks anyway.
+	ScrobbleQueueLimit  = 1000
+	ScrobbleQueueMaxAge = 14 * 24 * time.Hour
+)
+
+// Scrobbling service.
+type ScrobbleService interface {
+	// Name of the service.
+	Name() string
+	// Report the track is playing now.
+	NowPlaying(track TrackInfo) error
+	// Submit the track played at given time.
+	Scrobble(track TrackInfo, at time.Time) error
+}
+
+// ScrobbleError is an error reported by the service.
+// Permanent error means the service will never accept the request, eg due to invalid parameters or credentials.
+type ScrobbleError struct {
+	Message   string
+	Permanent bool
+}
+
+func (e *ScrobbleError) Error() string {
+	return e.Message
+}
+
+// Make an error of failed HTTP request. Client errors are permanent, except rate limiting.
+func httpScrobbleError(status int, message string) error {
+	return &ScrobbleError{
+		Message:   message,
+		Permanent: status >= 400 && status < 500 && status != http.StatusTooManyRequests,
+	}
+}
+
+// Check the failed request may succeed later. Network errors and server failures are temporary.
+func isRetryable(err error) bool {
+	var se *ScrobbleError
+	return !errors.As(err, &se) || !se.Permanent
+}
+
+// Scrobble waiting for retry.
+type Scrobble struct {
+	Service string    `json:"service"`
+	Time    time.Time `json:"time"`
+	Track   TrackInfo `json:"track"`
+}
+
+// Scrobbles config.
+type ScrobbleConfig struct {
+	LastFM       LastFMConfig       `json:"lastfm"`
+	ListenBrainz ListenBrainzConfig `json:"listenbrainz"`
+}
+
+// Scrobbler listens player events and submits played tracks to the scrobbling services.
+// Failed scrobbles are saved to the queue and submitted again with the next scrobble.
+type Scrobbler struct {
+	services  []ScrobbleService
+	queuePath string
+	onError   func(err error)
+
+	muxFlush  sync.Mutex
+	muxQueue  sync.Mutex
+	queue     []Scrobble
+	mux       sync.Mutex
+	current   *TrackInfo
+	started   time.Time
+	played    time.Duration
+	resumedAt time.Time
+	status    Status
+}
+
+// The constructor.
+// The queue of failed scrobbles is kept in queuePath, onError receives errors of background submissions.
+func NewScrobbler(queuePath string, onError func(err error), services ...ScrobbleService) *Scrobbler {
+	s := Scrobbler{
+		services:  services,
+		queuePath: queuePath,
+		onError:   onError,
+		queue:     make([]Scrobble, 0),
+		status:    StatusPlay,
+	}
+	if FileExists(queuePath) {
+		if err := UnmarshalFile(queuePath, &s.queue); err != nil {
+			onError(err)
+		}
+	}
+	if len(s.queue) > 0 {
+		go s.flush()
+	}
+	return &s
+}
+
+// Load scrobbler of the bundle.
+// Creates the config with disabled services if it doesn't exists. Returns nil if no service is enabled.
+func LoadScrobbler(bundle string, onError func(err error)) (*Scrobbler, error) {
+	path, _ := GetScrobblePath(bundle)
+	conf := ScrobbleConfig{
+		LastFM:       LastFMConfig{API: LastFMAPI},
+		ListenBrainz: ListenBrainzConfig{API: ListenBrainzAPI},
+	}
+	// Config contains credentials, so it's readable by the owner only.
+	if !FileExists(path) {
+		return nil, MarshalPrivateFile(path, conf, true)
+	}
+	if err := UnmarshalFile(path, &conf); err != nil {
+		return nil, err
+	}
+	// Config may be created readable by others before.
+	if err := os.Chmod(path, 0600); err != nil {
+		return nil, err
+	}
+
+	services := make([]ScrobbleService, 0)
+	if conf.LastFM.Enabled {
+		lfm := NewLastFM(conf.LastFM)
+		if len(conf.LastFM.SessionKey) == 0 {
+			// Exchange username and password to the session key and forget the password.
+			key, err := lfm.Authenticate()
+			if err != nil {
+				return nil, err
+			}
+			conf.LastFM.SessionKey, conf.LastFM.Password = key, ""
+			if err := MarshalPrivateFile(path, conf, true); err != nil {
+				return nil, err
+			}
+		}
+		services = append(services, lfm)
+	}
+	if conf.ListenBrainz.Enabled {
+		services = append(services, NewListenBrainz(conf.ListenBrainz))
+	}
+	if len(services) == 0 {
+		return nil, nil
+	}
+
+	queuePath, _ := GetScrobbleQueuePath(bundle)
+	return NewScrobbler(queuePath, onError, services...), nil
+}
+
+// Handle player event, see Listener.
+func (s *Scrobbler) Listen(e Event) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	switch e.Type {
+	case EventTrackStart:
+		track := e.Track
+		s.current, s.started, s.played, s.resumedAt = &track, e.Time, 0, e.Time
+		if s.status == StatusPlay {
+			go s.nowPlaying(track)
+		}
+	case EventStatus:
+		if s.current != nil && s.status == StatusPlay && e.Status != StatusPlay {
+			s.played += e.Time.Sub(s.resumedAt)
+		}
+		if e.Status == StatusPlay {
+			s.resumedAt = e.Time
+		}
+		s.status = e.Status
+	case EventTrackFinish:
+		if s.current == nil || s.current.Id != e.Track.Id {
+			return nil
+		}
+		if s.status == StatusPlay {
+			s.played += e.Time.Sub(s.resumedAt)
+		}
+		track, started, played := *s.current, s.started, s.played
+		s.current = nil
+
+		length := time.Duration(track.Length * float64(time.Second))
+		switch {
+		case length == 0:
+			// Length is unknown, so the track must be played long enough.
+			if played < ScrobbleMinPlayed {
+				return nil
+			}
+		case length < ScrobbleMinLength || (played < length/2 && played < ScrobbleMinPlayed):
+			return nil
+		}
+		// Save the scrobble before submitting to keep it if the player exits right now.
+		s.muxQueue.Lock()
+		for _, svc := range s.services {
+			s.queue = append(s.queue, Scrobble{Service: svc.Name(), Time: started, Track: track})
+		}
+		err := MarshalFile(s.queuePath, s.queue, true)
+		s.muxQueue.Unlock()
+		go s.flush()
+		return err
+	}
+	return nil
+}
+
+// Report the track is playing now to all services.
+func (s *Scrobbler) nowPlaying(track TrackInfo) {
+	for _, svc := range s.services {
+		if err := svc.NowPlaying(track); err != nil {
+			s.onError(errors.New(svc.Name() + ": " + err.Error()))
+		}
+	}
+}
+
+// Submit queued scrobbles and keep failed ones in the queue.
+// Scrobbles rejected permanently and expired ones are dropped.
+func (s *Scrobbler) flush() {
+	s.muxFlush.Lock()
+	defer s.muxFlush.Unlock()
+
+	s.muxQueue.Lock()
+	queue := s.queue
+	s.queue = make([]Scrobble, 0)
+	s.muxQueue.Unlock()
+
+	failed := make([]Scrobble, 0)
+	for _, sc := range queue {
+		svc := s.service(sc.Service)
+		if svc == nil {
+			// Service has been disabled, drop its scrobbles.
+			continue
+		}
+		if time.Since(sc.Time) > ScrobbleQueueMaxAge {
+			s.onError(fmt.Errorf("%s: scrobble of %s is expired and dropped", svc.Name(), sc.Track.Title))
+			continue
+		}
+		err := svc.Scrobble(sc.Track, sc.Time)
+		switch {
+		case err == nil:
+		case isRetryable(err):
+			s.onError(errors.New(svc.Name() + ": " + err.Error()))
+			failed = append(failed, sc)
+		default:
+			s.onError(fmt.Errorf("%s: scrobble of %s is rejected and dropped: %s", svc.Name(), sc.Track.Title, err))
+		}
+	}
+
+	s.muxQueue.Lock()
+	defer s.muxQueue.Unlock()
+	s.queue = append(failed, s.queue...)
+	if n := len(s.queue) - ScrobbleQueueLimit; n > 0 {
+		s.onError(fmt.Errorf("scrobble queue is full, %d oldest scrobbles are dropped", n))
+		s.queue = append(s.queue[:0], s.queue[n:]...)
+	}
+	if err := MarshalFile(s.queuePath, s.queue, true); err != nil {
+		s.onError(err)
+	}
+}
+
+// Get service by name.
+func (s *Scrobbler) service(name string) ScrobbleService {
+	for _, svc := range s.services {
+		if svc.Name() == name {
+			return svc
+		}
+	}
+	return nil
+}
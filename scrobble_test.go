@@ -0,0 +1,215 @@
+package conply
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ListenBrainz stub counting accepted scrobbles.
+// It fails while fail is set and rejects scrobbles as invalid while reject is set.
+type testScrobbleServer struct {
+	*httptest.Server
+	fail     atomic.Bool
+	reject   atomic.Bool
+	mux      sync.Mutex
+	accepted []string
+}
+
+func newTestScrobbleServer(t *testing.T) *testScrobbleServer {
+	s := &testScrobbleServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var l testListen
+		_ = json.NewDecoder(r.Body).Decode(&l)
+		if l.ListenType != "single" {
+			return
+		}
+		if s.fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if s.reject.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.mux.Lock()
+		s.accepted = append(s.accepted, l.Payload[0].Metadata.Track)
+		s.mux.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testScrobbleServer) Accepted() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]string(nil), s.accepted...)
+}
+
+// Play the track during given time.
+func testPlay(t *testing.T, s *Scrobbler, track TrackInfo, played time.Duration) {
+	start := time.Now()
+	if err := s.Listen(Event{Type: EventTrackStart, Time: start, Track: track}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Listen(Event{Type: EventTrackFinish, Time: start.Add(played), Track: track}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Read the saved queue, the lock keeps background flush from rewriting it meanwhile.
+func testQueue(t *testing.T, s *Scrobbler) []Scrobble {
+	s.muxQueue.Lock()
+	defer s.muxQueue.Unlock()
+	var queue []Scrobble
+	if err := UnmarshalFile(s.queuePath, &queue); err != nil {
+		t.Fatal(err)
+	}
+	return queue
+}
+
+func TestScrobblerQueue(t *testing.T) {
+	srv := newTestScrobbleServer(t)
+	srv.fail.Store(true)
+	path := filepath.Join(t.TempDir(), "queue.json")
+	var errs atomic.Int32
+	s := NewScrobbler(path, func(error) { errs.Add(1) }, NewListenBrainz(ListenBrainzConfig{API: srv.URL}))
+
+	testPlay(t, s, TrackInfo{Id: "1", Title: "first", Length: 300}, 3*time.Minute)
+	// Scrobble is saved before submitting, failed one stays in the queue.
+	s.flush()
+	if queue := testQueue(t, s); len(queue) != 1 || queue[0].Service != "listenbrainz" || queue[0].Track.Title != "first" {
+		t.Fatalf("unexpected queue %+v", queue)
+	}
+	if errs.Load() == 0 {
+		t.Error("failed scrobble isn't reported")
+	}
+
+	// Queue is submitted with the next scrobble.
+	srv.fail.Store(false)
+	testPlay(t, s, TrackInfo{Id: "2", Title: "second", Length: 300}, 3*time.Minute)
+	s.flush()
+	if queue := testQueue(t, s); len(queue) != 0 {
+		t.Errorf("queue isn't empty: %+v", queue)
+	}
+	if acc := srv.Accepted(); len(acc) != 2 || acc[0] != "first" || acc[1] != "second" {
+		t.Errorf("accepted %v, want first and second", acc)
+	}
+}
+
+func TestScrobblerLoadQueue(t *testing.T) {
+	srv := newTestScrobbleServer(t)
+	path := filepath.Join(t.TempDir(), "queue.json")
+	queue := []Scrobble{
+		{Service: "listenbrainz", Time: time.Now(), Track: TrackInfo{Title: "saved"}},
+		// Scrobbles of disabled service are dropped.
+		{Service: "lastfm", Time: time.Now(), Track: TrackInfo{Title: "disabled"}},
+	}
+	if err := MarshalFile(path, queue, false); err != nil {
+		t.Fatal(err)
+	}
+	// Errors aren't checked since the flush started by the constructor may outlive the test.
+	s := NewScrobbler(path, func(error) {}, NewListenBrainz(ListenBrainzConfig{API: srv.URL}))
+	s.flush()
+	if acc := srv.Accepted(); len(acc) != 1 || acc[0] != "saved" {
+		t.Errorf("accepted %v, want saved", acc)
+	}
+	if queue := testQueue(t, s); len(queue) != 0 {
+		t.Errorf("queue isn't empty: %+v", queue)
+	}
+}
+
+func TestScrobblerDropRejected(t *testing.T) {
+	srv := newTestScrobbleServer(t)
+	srv.reject.Store(true)
+	path := filepath.Join(t.TempDir(), "queue.json")
+	var errs atomic.Int32
+	s := NewScrobbler(path, func(error) { errs.Add(1) }, NewListenBrainz(ListenBrainzConfig{API: srv.URL}))
+
+	testPlay(t, s, TrackInfo{Id: "1", Title: "invalid", Length: 300}, 3*time.Minute)
+	s.flush()
+	// Rejected scrobble won't be accepted later, so it isn't kept.
+	if queue := testQueue(t, s); len(queue) != 0 {
+		t.Errorf("rejected scrobble is kept: %+v", queue)
+	}
+	if errs.Load() == 0 {
+		t.Error("rejected scrobble isn't reported")
+	}
+}
+
+func TestScrobblerQueueLimits(t *testing.T) {
+	srv := newTestScrobbleServer(t)
+	srv.fail.Store(true)
+	path := filepath.Join(t.TempDir(), "queue.json")
+	queue := []Scrobble{{Service: "listenbrainz", Time: time.Now().Add(-ScrobbleQueueMaxAge - time.Hour), Track: TrackInfo{Title: "expired"}}}
+	for i := 0; i < ScrobbleQueueLimit+10; i++ {
+		queue = append(queue, Scrobble{Service: "listenbrainz", Time: time.Now(), Track: TrackInfo{Title: strconv.Itoa(i)}})
+	}
+	if err := MarshalFile(path, queue, false); err != nil {
+		t.Fatal(err)
+	}
+	s := NewScrobbler(path, func(error) {}, NewListenBrainz(ListenBrainzConfig{API: srv.URL}))
+	s.flush()
+
+	// Expired scrobble is dropped and the oldest ones are dropped to fit the limit.
+	queue = testQueue(t, s)
+	if len(queue) != ScrobbleQueueLimit || queue[0].Track.Title != "10" {
+		t.Errorf("queue of %d scrobbles starts from %q", len(queue), queue[0].Track.Title)
+	}
+}
+
+func TestScrobblerRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		length float64
+		played time.Duration
+		want   bool
+	}{
+		{"half played", 300, 150 * time.Second, true},
+		{"less than half", 300, 140 * time.Second, false},
+		{"long track played 4 minutes", 1200, 4 * time.Minute, true},
+		{"short track", 20, 20 * time.Second, false},
+		{"unknown length played 4 minutes", 0, 4 * time.Minute, true},
+		{"unknown length", 0, 3 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "queue.json")
+			s := NewScrobbler(path, func(error) {}, NewListenBrainz(ListenBrainzConfig{API: "http://127.0.0.1:0"}))
+			testPlay(t, s, TrackInfo{Id: "1", Length: tt.length}, tt.played)
+			s.muxQueue.Lock()
+			got := len(s.queue) > 0 || FileExists(path)
+			s.muxQueue.Unlock()
+			if got != tt.want {
+				t.Errorf("scrobbled %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrobblerPause(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	s := NewScrobbler(path, func(error) {}, NewListenBrainz(ListenBrainzConfig{API: "http://127.0.0.1:0"}))
+	start, track := time.Now(), TrackInfo{Id: "1", Length: 300}
+	events := []Event{
+		{Type: EventTrackStart, Time: start, Track: track},
+		{Type: EventStatus, Time: start.Add(time.Minute), Status: StatusPause},
+		{Type: EventStatus, Time: start.Add(time.Hour), Status: StatusPlay},
+		{Type: EventTrackFinish, Time: start.Add(time.Hour + time.Minute), Track: track},
+	}
+	for _, e := range events {
+		if err := s.Listen(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only 2 minutes of 5 have been played, the pause isn't counted.
+	if FileExists(path) {
+		t.Error("paused time is counted as played")
+	}
+}
@@ -0,0 +1,32 @@
+package conply
+
+// Session state to resume playing on the next start, it's saved per bundle.
+type Session struct {
+	Bundle string `json:"bundle"`
+	// Station key, may be empty for single station bundles.
+	Station string `json:"station,omitempty"`
+	// Group ID, may be empty if bundle doesn't use groups.
+	Group   uint64 `json:"group,omitempty"`
+	Channel uint64 `json:"channel"`
+	Paused  bool   `json:"paused"`
+	Volume  Volume `json:"volume"`
+}
+
+// Load session state from the file.
+func SessionFromFile(path string) (*Session, error) {
+	s := Session{Volume: Volume{Level: DefaultVolume}}
+	err := UnmarshalFile(path, &s)
+	return &s, err
+}
+
+// Save session state to the file.
+// Session without channel, eg if the player exits at the channel picker, keeps the channel and the state of the saved
+// one, so the last played channel may be resumed.
+func (s *Session) Save(path string) error {
+	if s.Channel == 0 {
+		if prev, err := SessionFromFile(path); err == nil {
+			s.Station, s.Group, s.Channel, s.Paused = prev.Station, prev.Group, prev.Channel, prev.Paused
+		}
+	}
+	return MarshalFile(path, s, true)
+}
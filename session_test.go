@@ -0,0 +1,44 @@
+package conply
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	s := Session{Bundle: "xradio", Station: "di", Channel: 3, Paused: true, Volume: Volume{Level: 40}}
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := SessionFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != s {
+		t.Errorf("loaded session %+v, want %+v", *got, s)
+	}
+
+	// Player exited at the channel picker, the saved channel is kept, but the volume is updated.
+	s = Session{Bundle: "xradio", Station: "jazzradio", Volume: Volume{Level: 60, Muted: true}}
+	if err = s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = SessionFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	want := Session{Bundle: "xradio", Station: "di", Channel: 3, Paused: true, Volume: Volume{Level: 60, Muted: true}}
+	if *got != want {
+		t.Errorf("loaded session %+v, want %+v", *got, want)
+	}
+}
+
+func TestSessionFromFileDefaults(t *testing.T) {
+	s, err := SessionFromFile(filepath.Join(t.TempDir(), "session.json"))
+	if err == nil {
+		t.Error("expected error of missing session")
+	}
+	if s.Volume.Level != DefaultVolume {
+		t.Errorf("volume %d of missing session, want default", s.Volume.Level)
+	}
+}
@@ -0,0 +1,94 @@
+package conply
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Duration of slow fade out when the sleep timer is over.
+	SleepFadeDuration = 30 * time.Second
+	// Duration of volume ramp on wake up.
+	WakeFadeDuration = 2 * time.Minute
+)
+
+// Sleep timer presets cycled by sig-sleep signal.
+var SleepPresets = []time.Duration{15 * time.Minute, 30 * time.Minute, 45 * time.Minute, time.Hour, 90 * time.Minute}
+
+// Sleep timer calls the function when the time is over.
+type SleepTimer struct {
+	mux   sync.Mutex
+	timer *time.Timer
+	at    time.Time
+	fn    func()
+}
+
+// The constructor.
+func NewSleepTimer(fn func()) *SleepTimer {
+	return &SleepTimer{fn: fn}
+}
+
+// Start the timer, zero duration turns it off.
+// Returns the time the timer goes off, zero if the timer is off.
+func (s *SleepTimer) Set(d time.Duration) time.Time {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.at = time.Time{}
+	if d > 0 {
+		s.at = time.Now().Add(d)
+		s.timer = time.AfterFunc(d, s.fire)
+	}
+	return s.at
+}
+
+// Switch to the next preset longer than the time left, the timer turns off after the longest preset.
+// Returns the time the timer goes off, zero if the timer is off.
+func (s *SleepTimer) Cycle() time.Time {
+	left := time.Until(s.At())
+	for _, d := range SleepPresets {
+		// Give a minute to press the key again.
+		if d > left+time.Minute {
+			return s.Set(d)
+		}
+	}
+	return s.Set(0)
+}
+
+// Get the time the timer goes off, zero if the timer is off.
+func (s *SleepTimer) At() time.Time {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.at
+}
+
+func (s *SleepTimer) fire() {
+	s.mux.Lock()
+	s.timer, s.at = nil, time.Time{}
+	s.mux.Unlock()
+	s.fn()
+}
+
+// Get the next time of the clock given as "15:04", today or tomorrow.
+func NextClock(clock string, now time.Time) (time.Time, error) {
+	t, err := time.ParseInLocation("15:04", clock, now.Location())
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// Wait until the given time.
+// Wall clock is checked every minute, so waiting isn't delayed by suspend of the system.
+func WaitUntil(t time.Time) {
+	for left := time.Until(t); left > 0; left = time.Until(t) {
+		time.Sleep(min(left, time.Minute))
+	}
+}
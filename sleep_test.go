@@ -0,0 +1,72 @@
+package conply
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSleepTimerCycle(t *testing.T) {
+	s := NewSleepTimer(func() {})
+	defer s.Set(0)
+	// Each press switches to the next preset, the timer turns off after the longest one.
+	for _, d := range SleepPresets {
+		if left := time.Until(s.Cycle()); left <= d-time.Second || left > d {
+			t.Fatalf("timer goes off in %s, want %s", left, d)
+		}
+	}
+	if at := s.Cycle(); !at.IsZero() || !s.At().IsZero() {
+		t.Fatalf("timer isn't off after the longest preset")
+	}
+	if left := time.Until(s.Cycle()); left <= SleepPresets[0]-time.Second || left > SleepPresets[0] {
+		t.Errorf("timer goes off in %s, want the first preset", left)
+	}
+
+	// Timer set apart from presets switches to the next longer preset.
+	s.Set(20 * time.Minute)
+	if left := time.Until(s.Cycle()); left <= 30*time.Minute-time.Second || left > 30*time.Minute {
+		t.Errorf("timer goes off in %s, want 30m", left)
+	}
+}
+
+func TestSleepTimerFire(t *testing.T) {
+	fired := make(chan struct{})
+	s := NewSleepTimer(func() { close(fired) })
+	s.Set(10 * time.Millisecond)
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("timer didn't go off")
+	}
+	if !s.At().IsZero() {
+		t.Error("timer isn't off after firing")
+	}
+}
+
+func TestNextClock(t *testing.T) {
+	loc := time.FixedZone("test", 3*60*60)
+	now := time.Date(2024, 5, 14, 14, 30, 0, 0, loc)
+	tests := []struct {
+		clock string
+		want  time.Time
+	}{
+		{"15:00", time.Date(2024, 5, 14, 15, 0, 0, 0, loc)},
+		{"7:05", time.Date(2024, 5, 15, 7, 5, 0, 0, loc)},
+		// Current time is tomorrow's.
+		{"14:30", time.Date(2024, 5, 15, 14, 30, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		got, err := NextClock(tt.clock, now)
+		if err != nil {
+			t.Errorf("NextClock(%q): %s", tt.clock, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("NextClock(%q) = %s, want %s", tt.clock, got, tt.want)
+		}
+	}
+	for _, clock := range []string{"25:00", "7am", ""} {
+		if _, err := NextClock(clock, now); err == nil {
+			t.Errorf("NextClock(%q): expected error", clock)
+		}
+	}
+}
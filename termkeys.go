@@ -0,0 +1,129 @@
+package conply
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+
+	kb "github.com/koykov/helpers/keybind"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Prefix of terminal keys in hotkeys config, eg "Term-space" or "Term-n".
+	TermKeyPrefix = "Term-"
+	// Signal to finish the player, handled by terminal keys itself.
+	SigQuit = "sig-quit"
+)
+
+var ErrNotTerminal = errors.New("stdin is not a terminal")
+
+// Names of special terminal keys and their input sequences.
+var termKeyNames = map[string]string{
+	"space":     " ",
+	"enter":     "\r",
+	"tab":       "\t",
+	"backspace": "\x7f",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+}
+
+// Load hotkeys config.
+func LoadHotkeys(path string) ([]*kb.Hotkey, error) {
+	hotkeys := make([]*kb.Hotkey, 0)
+	err := UnmarshalFile(path, &hotkeys)
+	return hotkeys, err
+}
+
+// Split hotkeys to X server and terminal ones.
+func SplitHotkeys(hotkeys []*kb.Hotkey) (x, term []*kb.Hotkey) {
+	for _, hk := range hotkeys {
+		if strings.HasPrefix(hk.Key, TermKeyPrefix) {
+			term = append(term, hk)
+		} else {
+			x = append(x, hk)
+		}
+	}
+	return
+}
+
+// Terminal keys.
+// Fallback of X server hotkeys: reads single keys from stdin and sends mapped signals to the catcher.
+// The terminal works in non-canonical mode without echo, but output and Ctrl-C work as usual.
+type TermKeys struct {
+	catcher kb.KeyCatcher
+	keys    map[string]string
+	fd      int
+	state   *unix.Termios
+	once    sync.Once
+}
+
+// The constructor.
+// Takes the terminal keys from hotkeys, keys without TermKeyPrefix are ignored.
+func NewTermKeys(catcher kb.KeyCatcher, hotkeys []*kb.Hotkey) (*TermKeys, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, ErrNotTerminal
+	}
+	t := TermKeys{
+		catcher: catcher,
+		keys:    make(map[string]string),
+		fd:      fd,
+		state:   state,
+	}
+	_, term := SplitHotkeys(hotkeys)
+	for _, hk := range term {
+		key := strings.TrimPrefix(hk.Key, TermKeyPrefix)
+		if seq, ok := termKeyNames[strings.ToLower(key)]; ok {
+			key = seq
+		}
+		t.keys[key] = hk.Signal
+	}
+
+	raw := *state
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	raw.Cc[unix.VMIN], raw.Cc[unix.VTIME] = 1, 0
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	OnHalt(t.release)
+	return &t, nil
+}
+
+// Wait for key presses.
+func (t *TermKeys) Wait() {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		signal, ok := t.keys[string(buf[:n])]
+		if !ok {
+			continue
+		}
+		if signal == SigQuit {
+			_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+			continue
+		}
+		_ = t.catcher.Catch(signal)
+	}
+}
+
+// Restore the terminal.
+func (t *TermKeys) Release() error {
+	var err error
+	t.once.Do(func() {
+		err = unix.IoctlSetTermios(t.fd, ioctlSetTermios, t.state)
+	})
+	return err
+}
+
+func (t *TermKeys) release() {
+	_ = t.Release()
+}
@@ -0,0 +1,26 @@
+package conply
+
+// Bundle independent description of the track.
+type TrackInfo struct {
+	Bundle string `json:"bundle"`
+	// Station key, may be empty for single station bundles.
+	Station   string `json:"station,omitempty"`
+	Channel   string `json:"channel"`
+	ChannelId uint64 `json:"channel_id"`
+	// Track ID in the source service.
+	Id     string `json:"track_id"`
+	Artist string `json:"artist"`
+	Title  string `json:"title"`
+	Album  string `json:"album,omitempty"`
+	ArtURL string `json:"art_url,omitempty"`
+	// Track length in seconds.
+	Length float64 `json:"length"`
+}
+
+// Get "Artist - Title" of the track.
+func trackTitle(track TrackInfo) string {
+	if len(track.Artist) == 0 {
+		return track.Title
+	}
+	return track.Artist + " - " + track.Title
+}
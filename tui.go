@@ -0,0 +1,619 @@
+package conply
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const (
+	// Number of recent tracks to show.
+	TUIHistoryLen = 10
+	// Number of log lines to keep.
+	TUILogLen = 100
+)
+
+// Player which knows tracks to play next.
+type Scheduler interface {
+	Upcoming() []TrackInfo
+}
+
+// Player which tracks download jobs.
+type Downloader interface {
+	Downloads() []DownloadJob
+}
+
+var reANSI = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// Full-screen terminal UI.
+// Shows channels browser with incremental search, now playing track, upcoming tracks, recent history,
+// download jobs and log. Player output is captured to the log while the UI is active.
+type TUI struct {
+	bundle  string
+	ply     Player
+	np      *NowPlaying
+	signals map[string]bool
+	screen  tcell.Screen
+	stdout  *os.File
+	logw    *os.File
+	once    sync.Once
+	done    chan struct{}
+
+	mux       sync.Mutex
+	items     []tuiItem
+	filtered  []int
+	cursor    int
+	offset    int
+	query     string
+	searching bool
+	history   []HistoryRecord
+	logs      []string
+	message   string
+}
+
+// Channels browser item.
+type tuiItem struct {
+	id       uint64
+	title    string
+	favorite bool
+}
+
+// The constructor.
+// Takes the terminal and starts to handle keys. Now playing state must be subscribed to player events before UI.
+// Signals is a list of additional signals supported by the player, eg "sig-next".
+func NewTUI(bundle string, ply Player, np *NowPlaying, signals ...string) (*TUI, error) {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return nil, err
+	}
+	if err = screen.Init(); err != nil {
+		return nil, err
+	}
+	t := TUI{
+		bundle:  bundle,
+		ply:     ply,
+		np:      np,
+		signals: make(map[string]bool, len(signals)),
+		screen:  screen,
+		done:    make(chan struct{}),
+	}
+	for _, sig := range signals {
+		t.signals[sig] = true
+	}
+	t.loadItems()
+	path, _ := GetHistoryPath(bundle)
+	if recs, err := HistoryFromFile(path); err == nil {
+		for i := len(recs) - 1; i >= 0 && len(t.history) < TUIHistoryLen; i-- {
+			t.history = append(t.history, recs[i])
+		}
+	}
+
+	// Capture output of the player to show it in the log.
+	r, w, err := os.Pipe()
+	if err != nil {
+		screen.Fini()
+		return nil, err
+	}
+	t.stdout, t.logw, os.Stdout = os.Stdout, w, w
+	go t.capture(r)
+
+	OnHalt(t.release)
+	go t.loop()
+	go t.tick()
+	return &t, nil
+}
+
+// Handle player event, see Listener.
+func (t *TUI) Listen(e Event) error {
+	t.mux.Lock()
+	switch e.Type {
+	case EventTrackStart:
+		t.markFavorites()
+	case EventTrackFinish:
+		rec := HistoryRecord{Time: e.Time, TrackInfo: e.Track, Skipped: e.Skipped}
+		t.history = append([]HistoryRecord{rec}, t.history...)
+		if len(t.history) > TUIHistoryLen {
+			t.history = t.history[:TUIHistoryLen]
+		}
+	}
+	t.mux.Unlock()
+	t.redraw()
+	return nil
+}
+
+// Restore the terminal and player output.
+func (t *TUI) Release() error {
+	t.release()
+	return nil
+}
+
+func (t *TUI) release() {
+	t.once.Do(func() {
+		close(t.done)
+		t.screen.Fini()
+		os.Stdout = t.stdout
+		_ = t.logw.Close()
+	})
+}
+
+// Load channels and favorites of the player.
+func (t *TUI) loadItems() {
+	nav, ok := t.ply.(Navigator)
+	if !ok {
+		return
+	}
+	cat := nav.Catalog()
+	for _, g := range cat {
+		for _, c := range g.Channels {
+			title := c.Title
+			if len(cat) > 1 {
+				title = g.Title + " / " + c.Title
+			}
+			t.items = append(t.items, tuiItem{id: c.Id, title: title})
+		}
+	}
+	t.markFavorites()
+	t.filter()
+}
+
+// Update favorite marks of channels.
+func (t *TUI) markFavorites() {
+	nav, ok := t.ply.(Navigator)
+	if !ok {
+		return
+	}
+	favs := make(map[uint64]bool)
+	for _, fav := range nav.Favorites() {
+		favs[fav.Channel] = true
+	}
+	for i := range t.items {
+		t.items[i].favorite = favs[t.items[i].id]
+	}
+}
+
+// Apply the search query to channels list.
+func (t *TUI) filter() {
+	q := strings.ToLower(t.query)
+	t.filtered = t.filtered[:0]
+	for i, item := range t.items {
+		if len(q) == 0 || strings.Contains(strings.ToLower(item.title), q) {
+			t.filtered = append(t.filtered, i)
+		}
+	}
+	t.cursor, t.offset = 0, 0
+}
+
+// Read player output line by line.
+func (t *TUI) capture(r *os.File) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := reANSI.ReplaceAllString(scanner.Text(), "")
+		t.mux.Lock()
+		t.logs = append(t.logs, line)
+		if len(t.logs) > TUILogLen {
+			t.logs = t.logs[len(t.logs)-TUILogLen:]
+		}
+		t.mux.Unlock()
+		t.redraw()
+	}
+}
+
+// Redraw the screen every second to update the track time.
+func (t *TUI) tick() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.redraw()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// Ask the loop to redraw the screen.
+func (t *TUI) redraw() {
+	_ = t.screen.PostEvent(tcell.NewEventInterrupt(nil))
+}
+
+// Handle terminal events.
+func (t *TUI) loop() {
+	t.draw()
+	for {
+		ev := t.screen.PollEvent()
+		if ev == nil {
+			// Screen has been finalized.
+			return
+		}
+		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			t.screen.Sync()
+		case *tcell.EventKey:
+			if action := t.key(ev); action != nil {
+				action()
+			}
+		}
+		t.draw()
+	}
+}
+
+// Handle the key and get the action to run.
+// Actions are run without the lock since player emits events to UI while handling them.
+func (t *TUI) key(ev *tcell.EventKey) func() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.message = ""
+
+	switch ev.Key() {
+	case tcell.KeyUp:
+		t.move(-1)
+		return nil
+	case tcell.KeyDown:
+		t.move(1)
+		return nil
+	case tcell.KeyPgUp:
+		t.move(-t.listHeight())
+		return nil
+	case tcell.KeyPgDn:
+		t.move(t.listHeight())
+		return nil
+	case tcell.KeyHome:
+		t.move(-len(t.filtered))
+		return nil
+	case tcell.KeyEnd:
+		t.move(len(t.filtered))
+		return nil
+	case tcell.KeyEnter:
+		t.searching = false
+		if len(t.filtered) == 0 {
+			return nil
+		}
+		return t.play(t.items[t.filtered[t.cursor]].id)
+	case tcell.KeyCtrlC:
+		return t.quit
+	}
+
+	if t.searching {
+		switch ev.Key() {
+		case tcell.KeyEscape:
+			t.searching, t.query = false, ""
+			t.filter()
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if r := []rune(t.query); len(r) > 0 {
+				t.query = string(r[:len(r)-1])
+				t.filter()
+			}
+		case tcell.KeyRune:
+			t.query += string(ev.Rune())
+			t.filter()
+		}
+		return nil
+	}
+
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		t.query = ""
+		t.filter()
+		return nil
+	case tcell.KeyRune:
+	default:
+		return nil
+	}
+	switch ev.Rune() {
+	case '/':
+		t.searching = true
+	case 'k':
+		t.move(-1)
+	case 'j':
+		t.move(1)
+	case ' ', 'p':
+		return t.catch("sig-toggle-pause")
+	case 'n':
+		if !t.signals["sig-next"] {
+			t.message = "Skipping isn't supported by " + t.bundle
+			return nil
+		}
+		return t.catch("sig-next")
+	case 'b':
+		if !t.signals["sig-prev"] {
+			t.message = "Previous track isn't supported by " + t.bundle
+			return nil
+		}
+		return t.catch("sig-prev")
+	case 'r':
+		if !t.signals["sig-replay"] {
+			t.message = "Replaying isn't supported by " + t.bundle
+			return nil
+		}
+		return t.catch("sig-replay")
+	case 'y', 'x':
+		sig := "sig-like"
+		if ev.Rune() == 'x' {
+			sig = "sig-dislike"
+		}
+		if !t.signals[sig] {
+			t.message = "Voting isn't supported by " + t.bundle
+			return nil
+		}
+		return t.catch(sig)
+	case 'd':
+		return t.catch("sig-download")
+	case '+', '=':
+		return t.catch("sig-volume-up")
+	case '-':
+		return t.catch("sig-volume-down")
+	case 'm':
+		return t.catch("sig-mute")
+	case 's':
+		return t.catch("sig-sleep")
+	case 'f':
+		catch := t.catch("sig-favorite")
+		return func() {
+			catch()
+			t.mux.Lock()
+			t.markFavorites()
+			t.mux.Unlock()
+		}
+	case 'q':
+		return t.quit
+	}
+	return nil
+}
+
+// Move the cursor.
+func (t *TUI) move(delta int) {
+	t.cursor += delta
+	if t.cursor >= len(t.filtered) {
+		t.cursor = len(t.filtered) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+// Get action to switch to the channel.
+func (t *TUI) play(id uint64) func() {
+	nav, ok := t.ply.(Navigator)
+	if !ok {
+		return nil
+	}
+	return func() {
+		t.setError(nav.SwitchChannel(id))
+	}
+}
+
+// Get action to send the signal to the player.
+func (t *TUI) catch(signal string) func() {
+	return func() {
+		t.setError(t.ply.Catch(signal))
+	}
+}
+
+// Show the error in the log.
+func (t *TUI) setError(err error) {
+	if err == nil {
+		return
+	}
+	t.mux.Lock()
+	t.message = err.Error()
+	t.mux.Unlock()
+}
+
+// Finish the player, it will release the UI.
+func (t *TUI) quit() {
+	_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+}
+
+// Get height of channels list.
+func (t *TUI) listHeight() int {
+	_, h := t.screen.Size()
+	// Header, now playing, separators, log and help lines, see draw().
+	return h - 11
+}
+
+// Draw the whole screen.
+func (t *TUI) draw() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	s := t.screen
+	s.Clear()
+	w, h := s.Size()
+	var (
+		normal = tcell.StyleDefault
+		bold   = normal.Bold(true)
+		dim    = normal.Dim(true)
+		title  = normal.Reverse(true)
+		accent = normal.Foreground(tcell.ColorTeal)
+	)
+
+	// Header.
+	status := t.np.Status()
+	fill(s, 0, 0, w, title)
+	text(s, 1, 0, w-2, title, t.bundle+" — "+status.String())
+	volume, muted := t.np.Volume()
+	vol := fmt.Sprintf("vol %d%%", volume)
+	if muted {
+		vol = "muted"
+	}
+	if at := t.np.SleepAt(); !at.IsZero() {
+		vol = "sleep " + FormatTime(uint64(time.Until(at).Seconds())) + "  " + vol
+	}
+	text(s, w-len(vol)-1, 0, len(vol), title, vol)
+
+	// Now playing.
+	track := t.np.Track()
+	if track == nil {
+		text(s, 1, 2, w-2, dim, "Nothing is playing, choose the channel and press Enter")
+	} else {
+		text(s, 1, 2, w-2, bold, track.Artist+" - "+track.Title)
+		about := track.Channel
+		if len(track.Album) > 0 {
+			about = track.Album + " · " + about
+		}
+		text(s, 1, 3, w-2, dim, about)
+		t.drawProgress(1, 4, w-2, track)
+	}
+
+	// Channels browser on the left, other panes on the right.
+	top := 6
+	bottom := h - 5
+	left := w
+	if w >= 80 {
+		left = w * 3 / 5
+	}
+	header := fmt.Sprintf("Channels (%d)  / search", len(t.filtered))
+	if t.searching || len(t.query) > 0 {
+		header = "Search: " + t.query
+		if t.searching {
+			header += "_"
+		}
+	}
+	fill(s, 0, top-1, w, dim.Underline(true))
+	text(s, 1, top-1, left-2, bold.Underline(true), header)
+	height := bottom - top
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+height {
+		t.offset = t.cursor - height + 1
+	}
+	for i := 0; i < height && t.offset+i < len(t.filtered); i++ {
+		item := t.items[t.filtered[t.offset+i]]
+		style := normal
+		if track != nil && item.id == track.ChannelId {
+			style = accent
+		}
+		if t.offset+i == t.cursor {
+			style = style.Reverse(true)
+			fill(s, 0, top+i, left-1, style)
+		}
+		mark := "  "
+		if item.favorite {
+			mark = "★ "
+		}
+		text(s, 1, top+i, left-3, style, mark+item.title)
+	}
+	if left < w {
+		t.drawPanes(left+1, top, w-left-2, bottom-top)
+	}
+
+	// Log and help.
+	fill(s, 0, bottom, w, dim.Underline(true))
+	text(s, 1, bottom, w-2, bold.Underline(true), "Log")
+	logs := t.logs[max(0, len(t.logs)-3):]
+	if len(t.message) > 0 {
+		logs = append(append([]string{}, t.logs[max(0, len(t.logs)-2):]...), t.message)
+	}
+	for i, line := range logs {
+		text(s, 1, bottom+1+i, w-2, normal, line)
+	}
+	help := "↑↓ move  Enter play  / search  Space pause  d download  f favorite  +- volume  m mute  s sleep  q quit"
+	if t.signals["sig-next"] {
+		help = "↑↓ move  Enter play  / search  Space pause  n next  d download  f favorite  +- volume  m mute  s sleep  q quit"
+	}
+	if t.signals["sig-prev"] {
+		help = "↑↓ move  Enter play  / search  Space pause  n next  b prev  r replay  y like  x dislike  d download  f favorite  +- volume  m mute  s sleep  q quit"
+	}
+	fill(s, 0, h-1, w, title)
+	text(s, 1, h-1, w-2, title, help)
+	s.Show()
+}
+
+// Draw progress bar of the track with elapsed and remaining time.
+func (t *TUI) drawProgress(x, y, w int, track *TrackInfo) {
+	pos := t.np.Position().Seconds()
+	length := track.Length
+	if length > 0 && pos > length {
+		pos = length
+	}
+	times := FormatTime(uint64(pos))
+	if length > 0 {
+		times += " / -" + FormatTime(uint64(length-pos))
+	}
+	bar := w - len(times) - 1
+	if bar > 0 && length > 0 {
+		done := int(float64(bar) * pos / length)
+		text(t.screen, x, y, bar, tcell.StyleDefault, strings.Repeat("━", done)+strings.Repeat("─", bar-done))
+	}
+	text(t.screen, x+w-len(times), y, len(times), tcell.StyleDefault, times)
+}
+
+// Draw upcoming tracks, recent history and downloads.
+func (t *TUI) drawPanes(x, y, w, h int) {
+	bold := tcell.StyleDefault.Bold(true)
+	dim := tcell.StyleDefault.Dim(true)
+	row := y
+	section := func(title string, lines []string) {
+		if len(lines) == 0 || row >= y+h {
+			return
+		}
+		text(t.screen, x, row, w, bold, title)
+		row++
+		for _, line := range lines {
+			if row >= y+h {
+				return
+			}
+			text(t.screen, x, row, w, dim, line)
+			row++
+		}
+		row++
+	}
+
+	if sch, ok := t.ply.(Scheduler); ok {
+		var lines []string
+		for _, tr := range sch.Upcoming() {
+			lines = append(lines, tr.Artist+" - "+tr.Title)
+		}
+		section("Up next", lines)
+	}
+	if dl, ok := t.ply.(Downloader); ok {
+		var lines []string
+		jobs := dl.Downloads()
+		for i := len(jobs) - 1; i >= 0; i-- {
+			j := jobs[i]
+			state := j.State.String()
+			if j.State == DownloadActive && j.Progress >= 0 {
+				state = fmt.Sprintf("%d%%", int(j.Progress*100))
+			}
+			lines = append(lines, fmt.Sprintf("[%s] %s - %s", state, j.Track.Artist, j.Track.Title))
+		}
+		section("Downloads", lines)
+	}
+	var lines []string
+	for _, rec := range t.history {
+		mark := ""
+		if rec.Skipped {
+			mark = " (skipped)"
+		}
+		lines = append(lines, rec.Time.Local().Format("15:04")+" "+rec.Artist+" - "+rec.Title+mark)
+	}
+	section("Recently played", lines)
+}
+
+// Fill the line with the style.
+func fill(s tcell.Screen, x, y, w int, style tcell.Style) {
+	for i := 0; i < w; i++ {
+		s.SetContent(x+i, y, ' ', nil, style)
+	}
+}
+
+// Draw the text cut to given width.
+func text(s tcell.Screen, x, y, w int, style tcell.Style, str string) {
+	i := 0
+	for _, r := range str {
+		if i >= w {
+			return
+		}
+		s.SetContent(x+i, y, r, nil, style)
+		i++
+	}
+}
@@ -0,0 +1,23 @@
+package vlc
+
+// #include <vlc/vlc.h>
+import "C"
+import (
+	"runtime/cgo"
+	"unsafe"
+)
+
+// Handle media player events, see attachEvents.
+// Note that libvlc functions must not be called from here.
+//
+//export vlcEvent
+func vlcEvent(e *C.libvlc_event_t, data unsafe.Pointer) {
+	vlc, ok := cgo.Handle(uintptr(data)).Value().(*Vlc)
+	if !ok {
+		return
+	}
+	switch e._type {
+	case C.libvlc_MediaPlayerEndReached, C.libvlc_MediaPlayerEncounteredError:
+		vlc.ended((*C.libvlc_media_player_t)(e.p_obj))
+	}
+}
@@ -0,0 +1,511 @@
+// Package vlc is the libvlc audio backend of the players.
+// Based on github.com/koykov/vlc, extended with volume control, fades and gapless playing.
+package vlc
+
+// #cgo LDFLAGS: -lvlc
+// #include <vlc/vlc.h>
+// #include <stdint.h>
+// #include <stdlib.h>
+//
+// extern void vlcEvent(libvlc_event_t*, void*);
+//
+// static void attachEvents(libvlc_media_player_t *p, uintptr_t data) {
+//     libvlc_event_manager_t *m = libvlc_media_player_event_manager(p);
+//     libvlc_event_attach(m, libvlc_MediaPlayerEndReached, (libvlc_callback_t)vlcEvent, (void*)data);
+//     libvlc_event_attach(m, libvlc_MediaPlayerEncounteredError, (libvlc_callback_t)vlcEvent, (void*)data);
+// }
+import "C"
+import (
+	"errors"
+	"os"
+	"runtime/cgo"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+const (
+	// Max volume, 100%.
+	MaxVolume = 100
+	// Interval between volume changes during the fade.
+	fadeStep = 20 * time.Millisecond
+	// Max time to wait the start of playing before fade in.
+	fadeWait = 5 * time.Second
+	// Interval of checking the time left to the end of media.
+	watchStep = 100 * time.Millisecond
+)
+
+var (
+	ErrNotPreloaded = errors.New("nothing is preloaded")
+	ErrReleased     = errors.New("vlc is released")
+)
+
+type Vlc struct {
+	instance *C.libvlc_instance_t
+	handle   cgo.Handle
+
+	// Current and preloaded media players. Preloaded one is paused until the current media ends.
+	mux       sync.Mutex
+	player    *C.libvlc_media_player_t
+	media     *C.libvlc_media_t
+	next      *C.libvlc_media_player_t
+	nextMedia *C.libvlc_media_t
+
+	// Volume set by user, actual volume may differ during the fade.
+	volume int
+	muted  bool
+
+	// Time before the end of media to start crossfade.
+	crossfade time.Duration
+	// Generation of the current media, it grows on each media change. Media pointers may be reused by libvlc,
+	// so generations are used to notify the ending once.
+	gen      uint64
+	notified uint64
+	ending   chan struct{}
+	done     chan struct{}
+	// Watcher and crossfades running in background, Release waits for them.
+	bg sync.WaitGroup
+}
+
+// The constructor.
+func NewVlc(args []string) (*Vlc, error) {
+	// Convert arguments.
+	argc := C.int(len(args))
+	argv := make([]*C.char, 0)
+	for _, arg := range args {
+		argv = append(argv, C.CString(arg))
+	}
+	defer func() {
+		for i := range argv {
+			C.free(unsafe.Pointer(argv[i]))
+		}
+	}()
+
+	// Make instance.
+	vlc := Vlc{
+		instance: C.libvlc_new(C.int(argc), *(***C.char)(unsafe.Pointer(&argv))),
+		volume:   MaxVolume,
+		ending:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	if err := vlc.getLastErr(); err != nil {
+		return nil, err
+	}
+
+	// Make player.
+	vlc.handle = cgo.NewHandle(&vlc)
+	if vlc.player = vlc.newPlayer(); vlc.player == nil {
+		vlc.handle.Delete()
+		return nil, vlc.getLastErr()
+	}
+	vlc.bg.Add(1)
+	go vlc.watch()
+
+	return &vlc, nil
+}
+
+// Make media player listening the end of media.
+func (vlc *Vlc) newPlayer() *C.libvlc_media_player_t {
+	player := C.libvlc_media_player_new(vlc.instance)
+	if player != nil {
+		C.attachEvents(player, C.uintptr_t(vlc.handle))
+	}
+	return player
+}
+
+// Get latest error from libvlc.
+func (vlc *Vlc) getLastErr() error {
+	if err := C.libvlc_errmsg(); err != nil {
+		return errors.New(C.GoString(err))
+	}
+	return nil
+}
+
+// Get the current media player.
+func (vlc *Vlc) current() *C.libvlc_media_player_t {
+	vlc.mux.Lock()
+	defer vlc.mux.Unlock()
+	return vlc.player
+}
+
+// Release VLC resources.
+func (vlc *Vlc) Release() error {
+	// Stop the watcher and crossfades, they use players released below.
+	close(vlc.done)
+	vlc.bg.Wait()
+	vlc.DropPreloaded()
+
+	// Event callbacks take the lock, so the player is released without it.
+	vlc.mux.Lock()
+	player, media := vlc.player, vlc.media
+	vlc.player, vlc.media = nil, nil
+	vlc.mux.Unlock()
+	C.libvlc_media_player_release(player)
+	if media != nil {
+		C.libvlc_media_release(media)
+	}
+
+	C.libvlc_release(vlc.instance)
+	vlc.instance = nil
+	vlc.handle.Delete()
+
+	return vlc.getLastErr()
+}
+
+// Play VLC media.
+func (vlc *Vlc) playMedia(media *C.libvlc_media_t) error {
+	player := vlc.current()
+	C.libvlc_media_player_set_media(player, media)
+	if err := vlc.getLastErr(); err != nil {
+		return err
+	}
+	if C.libvlc_media_player_play(player) < 0 {
+		return vlc.getLastErr()
+	}
+	return nil
+}
+
+// Replace the current media.
+func (vlc *Vlc) setMedia(media *C.libvlc_media_t) {
+	vlc.mux.Lock()
+	defer vlc.mux.Unlock()
+	if vlc.media != nil {
+		C.libvlc_media_release(vlc.media)
+	}
+	vlc.media = media
+	vlc.gen++
+	vlc.drain()
+}
+
+// Drop the notification about ending of the previous media.
+func (vlc *Vlc) drain() {
+	select {
+	case <-vlc.ending:
+	default:
+	}
+}
+
+// Play local media file.
+func (vlc *Vlc) Play(filepath string) error {
+	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+		return errors.New("not found: " + filepath)
+	}
+
+	filepathPtr := C.CString(filepath)
+	defer C.free(unsafe.Pointer(filepathPtr))
+
+	media := C.libvlc_media_new_path(vlc.instance, filepathPtr)
+	vlc.setMedia(media)
+	if media != nil {
+		return vlc.playMedia(media)
+	}
+
+	return vlc.getLastErr()
+}
+
+// Play remote file.
+func (vlc *Vlc) PlayURL(url string) error {
+	urlPtr := C.CString(url)
+	defer C.free(unsafe.Pointer(urlPtr))
+
+	media := C.libvlc_media_new_location(vlc.instance, urlPtr)
+	vlc.setMedia(media)
+	if media != nil {
+		return vlc.playMedia(media)
+	}
+
+	return vlc.getLastErr()
+}
+
+// Open remote file in the second media player and keep it paused, so PlayPreloaded starts it without gap.
+// Previously preloaded file is dropped.
+func (vlc *Vlc) Preload(url string) error {
+	vlc.DropPreloaded()
+
+	urlPtr := C.CString(url)
+	defer C.free(unsafe.Pointer(urlPtr))
+
+	media := C.libvlc_media_new_location(vlc.instance, urlPtr)
+	if media == nil {
+		return vlc.getLastErr()
+	}
+	player := vlc.newPlayer()
+	if player == nil {
+		C.libvlc_media_release(media)
+		return vlc.getLastErr()
+	}
+	C.libvlc_media_player_set_media(player, media)
+	// Start to buffer silently.
+	C.libvlc_audio_set_volume(player, 0)
+	if vlc.Muted() {
+		C.libvlc_audio_set_mute(player, 1)
+	}
+	if C.libvlc_media_player_play(player) < 0 {
+		C.libvlc_media_player_release(player)
+		C.libvlc_media_release(media)
+		return vlc.getLastErr()
+	}
+	C.libvlc_media_player_set_pause(player, 1)
+
+	vlc.mux.Lock()
+	vlc.next, vlc.nextMedia = player, media
+	vlc.mux.Unlock()
+	return nil
+}
+
+// Check if there is preloaded file.
+func (vlc *Vlc) Preloaded() bool {
+	vlc.mux.Lock()
+	defer vlc.mux.Unlock()
+	return vlc.next != nil
+}
+
+// Release preloaded file.
+func (vlc *Vlc) DropPreloaded() {
+	vlc.mux.Lock()
+	player, media := vlc.next, vlc.nextMedia
+	vlc.next, vlc.nextMedia = nil, nil
+	vlc.mux.Unlock()
+	if player != nil {
+		C.libvlc_media_player_stop(player)
+		C.libvlc_media_player_release(player)
+	}
+	if media != nil {
+		C.libvlc_media_release(media)
+	}
+}
+
+// Start the preloaded file and stop the current one.
+// Files are crossfaded in background during the given duration, zero means gapless switch.
+func (vlc *Vlc) PlayPreloaded(crossfade time.Duration) error {
+	vlc.mux.Lock()
+	if vlc.next == nil {
+		vlc.mux.Unlock()
+		return ErrNotPreloaded
+	}
+	prev, prevMedia := vlc.player, vlc.media
+	vlc.player, vlc.media = vlc.next, vlc.nextMedia
+	vlc.next, vlc.nextMedia = nil, nil
+	vlc.gen++
+	player := vlc.player
+	vlc.drain()
+	vlc.mux.Unlock()
+
+	C.libvlc_media_player_set_pause(player, 0)
+	err := vlc.getLastErr()
+	// The previous player isn't reachable by others, so it's released here even if the fade is canceled by Release.
+	vlc.bg.Add(1)
+	go func() {
+		defer vlc.bg.Done()
+		if err == nil {
+			_ = vlc.fade(crossfade, func(p float64) error {
+				volume := float64(vlc.Volume())
+				_ = setVolume(prev, int(volume*(1-p)))
+				return setVolume(player, int(volume*p))
+			})
+		}
+		C.libvlc_media_player_stop(prev)
+		C.libvlc_media_player_release(prev)
+		if prevMedia != nil {
+			C.libvlc_media_release(prevMedia)
+		}
+	}()
+	return err
+}
+
+// Set time before the end of media to notify about its ending, see Ending.
+func (vlc *Vlc) SetCrossfade(d time.Duration) {
+	vlc.mux.Lock()
+	defer vlc.mux.Unlock()
+	vlc.crossfade = d
+}
+
+// Notifies that the current media is about to end.
+// If a file is preloaded the notification comes the crossfade time before the end, otherwise at the end of media.
+// Playing errors are notified too, so the caller may go on to the next media.
+func (vlc *Vlc) Ending() <-chan struct{} {
+	return vlc.ending
+}
+
+// Notify about the ending of the media of given generation once.
+func (vlc *Vlc) notify(gen uint64) {
+	vlc.mux.Lock()
+	if gen == vlc.notified {
+		vlc.mux.Unlock()
+		return
+	}
+	vlc.notified = gen
+	vlc.mux.Unlock()
+	select {
+	case vlc.ending <- struct{}{}:
+	default:
+	}
+}
+
+// Handle the end of media reached by the media player.
+// Previous player may be still fading out, so ignore it.
+func (vlc *Vlc) ended(player *C.libvlc_media_player_t) {
+	vlc.mux.Lock()
+	current, gen := player == vlc.player, vlc.gen
+	vlc.mux.Unlock()
+	if current {
+		vlc.notify(gen)
+	}
+}
+
+// Check the time left to the end of media to start crossfade in time.
+func (vlc *Vlc) watch() {
+	defer vlc.bg.Done()
+	ticker := time.NewTicker(watchStep)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-vlc.done:
+			return
+		case <-ticker.C:
+		}
+		vlc.mux.Lock()
+		player, gen, crossfade, preloaded := vlc.player, vlc.gen, vlc.crossfade, vlc.next != nil
+		vlc.mux.Unlock()
+		if crossfade == 0 || !preloaded || C.libvlc_media_player_get_state(player) != C.libvlc_Playing {
+			continue
+		}
+		length := time.Duration(C.libvlc_media_player_get_length(player)) * time.Millisecond
+		elapsed := time.Duration(C.libvlc_media_player_get_time(player)) * time.Millisecond
+		if length > 0 && length-elapsed <= crossfade {
+			vlc.notify(gen)
+		}
+	}
+}
+
+// Returns position of played media.
+func (vlc *Vlc) Position() (float64, error) {
+	return float64(C.libvlc_media_player_get_position(vlc.current())), vlc.getLastErr()
+}
+
+// Pause playing.
+func (vlc *Vlc) Pause() error {
+	C.libvlc_media_player_set_pause(vlc.current(), C.int(1))
+	return vlc.getLastErr()
+}
+
+// Resume playing.
+func (vlc *Vlc) Resume() error {
+	C.libvlc_media_player_set_pause(vlc.current(), C.int(0))
+	return vlc.getLastErr()
+}
+
+// Toggle pause.
+func (vlc *Vlc) TogglePause() error {
+	C.libvlc_media_player_pause(vlc.current())
+	return vlc.getLastErr()
+}
+
+// Stop playing.
+func (vlc *Vlc) Stop() error {
+	C.libvlc_media_player_stop(vlc.current())
+	return vlc.getLastErr()
+}
+
+// Check if the media is playing.
+func (vlc *Vlc) IsPlaying() bool {
+	return C.libvlc_media_player_get_state(vlc.current()) == C.libvlc_Playing
+}
+
+// Get volume from 0 to 100.
+func (vlc *Vlc) Volume() int {
+	vlc.mux.Lock()
+	defer vlc.mux.Unlock()
+	return vlc.volume
+}
+
+// Set volume from 0 to 100.
+func (vlc *Vlc) SetVolume(volume int) error {
+	volume = min(max(volume, 0), MaxVolume)
+	vlc.mux.Lock()
+	vlc.volume = volume
+	vlc.mux.Unlock()
+	return setVolume(vlc.current(), volume)
+}
+
+// Set actual volume of the media player.
+func setVolume(player *C.libvlc_media_player_t, volume int) error {
+	if C.libvlc_audio_set_volume(player, C.int(volume)) < 0 {
+		if err := C.libvlc_errmsg(); err != nil {
+			return errors.New(C.GoString(err))
+		}
+	}
+	return nil
+}
+
+// Check if the output is muted.
+func (vlc *Vlc) Muted() bool {
+	vlc.mux.Lock()
+	defer vlc.mux.Unlock()
+	return vlc.muted
+}
+
+// Mute or unmute the output.
+func (vlc *Vlc) Mute(mute bool) error {
+	vlc.mux.Lock()
+	vlc.muted = mute
+	players := []*C.libvlc_media_player_t{vlc.player, vlc.next}
+	vlc.mux.Unlock()
+	status := 0
+	if mute {
+		status = 1
+	}
+	for _, player := range players {
+		if player != nil {
+			C.libvlc_audio_set_mute(player, C.int(status))
+		}
+	}
+	return vlc.getLastErr()
+}
+
+// Smoothly decrease actual volume to zero. The volume set by user keeps.
+// Does nothing if no media was played.
+func (vlc *Vlc) FadeOut(d time.Duration) error {
+	vlc.mux.Lock()
+	player, media := vlc.player, vlc.media
+	vlc.mux.Unlock()
+	if media == nil {
+		return nil
+	}
+	return vlc.fade(d, func(p float64) error {
+		return setVolume(player, int(float64(vlc.Volume())*(1-p)))
+	})
+}
+
+// Smoothly increase actual volume from zero to the volume set by user.
+// Waits while the media is opening or buffering, so the fade is audible.
+func (vlc *Vlc) FadeIn(d time.Duration) error {
+	player := vlc.current()
+	if err := setVolume(player, 0); err != nil {
+		return err
+	}
+	for deadline := time.Now().Add(fadeWait); !vlc.IsPlaying() && time.Now().Before(deadline); {
+		time.Sleep(fadeStep)
+	}
+	return vlc.fade(d, func(p float64) error {
+		return setVolume(player, int(float64(vlc.Volume())*p))
+	})
+}
+
+// Change actual volume step by step. Step function gets the part of passed time and sets the volume.
+// User volume should be read at each step, so it may be changed during the fade.
+// The fade is canceled by Release.
+func (vlc *Vlc) fade(d time.Duration, step func(p float64) error) error {
+	steps := int(d / fadeStep)
+	for i := 1; i < steps; i++ {
+		if err := step(float64(i) / float64(steps)); err != nil {
+			return err
+		}
+		select {
+		case <-vlc.done:
+			return ErrReleased
+		case <-time.After(fadeStep):
+		}
+	}
+	return step(1)
+}
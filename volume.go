@@ -0,0 +1,27 @@
+package conply
+
+import "time"
+
+const (
+	// Default volume, 100%.
+	DefaultVolume = 100
+	// Volume change by sig-volume-up and sig-volume-down signals.
+	VolumeStep = 5
+	// Duration of fade in/out on pause, resume and track switch.
+	FadeDuration = 300 * time.Millisecond
+)
+
+// Volume state saved between sessions.
+type Volume struct {
+	Level int  `json:"level"`
+	Muted bool `json:"muted"`
+}
+
+// Load volume state of the last session. Returns default volume if the session file doesn't exist.
+func VolumeFromSession(path string) (*Volume, error) {
+	if !FileExists(path) {
+		return &Volume{Level: DefaultVolume}, nil
+	}
+	s, err := SessionFromFile(path)
+	return &s.Volume, err
+}
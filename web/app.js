@@ -0,0 +1,197 @@
+'use strict';
+
+// Token is given once in the page URL and kept in the local storage.
+const params = new URLSearchParams(location.search);
+if (params.has('token')) {
+	localStorage.setItem('token', params.get('token'));
+	history.replaceState(null, '', location.pathname);
+}
+const token = localStorage.getItem('token') || '';
+
+const $ = (id) => document.getElementById(id);
+
+let state = {status: 'stopped', position: 0, track: null, updated: Date.now()};
+
+// Call the API and return decoded response.
+async function api(method, path) {
+	const resp = await fetch(path, {
+		method: method,
+		headers: token ? {'Authorization': 'Bearer ' + token} : {},
+	});
+	if (resp.status === 204) {
+		return null;
+	}
+	const data = await resp.json();
+	if (!resp.ok) {
+		throw new Error(data.error || resp.statusText);
+	}
+	return data;
+}
+
+function showError(err) {
+	const el = $('error');
+	el.textContent = err ? err.message : '';
+	el.hidden = !err;
+}
+
+function formatTime(sec) {
+	sec = Math.max(0, Math.floor(sec));
+	return Math.floor(sec / 60) + ':' + String(sec % 60).padStart(2, '0');
+}
+
+function item(text, className) {
+	const li = document.createElement('li');
+	li.textContent = text;
+	if (className) {
+		li.className = className;
+	}
+	return li;
+}
+
+// Render now playing card.
+function renderNow() {
+	const t = state.track;
+	$('title').textContent = t ? t.title : 'Nothing is playing';
+	$('artist').textContent = t ? t.artist + (t.album ? ' — ' + t.album : '') : '';
+	$('channel').textContent = t ? t.channel : '';
+	$('toggle').textContent = state.status === 'playing' ? 'Pause' : 'Resume';
+	if (t && t.art_url) {
+		$('cover').src = t.art_url;
+		$('cover').hidden = false;
+	} else {
+		$('cover').hidden = true;
+	}
+	for (const li of document.querySelectorAll('li.channel')) {
+		li.classList.toggle('current', !!t && li.dataset.id === String(t.channel_id));
+	}
+	renderTime();
+}
+
+function renderTime() {
+	let pos = state.position;
+	if (state.status === 'playing') {
+		pos += (Date.now() - state.updated) / 1000;
+	}
+	const length = state.track ? state.track.length : 0;
+	$('progress').max = length || 1;
+	$('progress').value = Math.min(pos, length);
+	$('time').textContent = state.track ? formatTime(pos) + ' / ' + formatTime(length) : '';
+}
+
+async function loadStatus() {
+	const s = await api('GET', '/api/status');
+	state = {status: s.status, position: s.position || 0, track: s.track || null, updated: Date.now()};
+	$('bundle').textContent = s.bundle;
+	// Skip is shown only if the player supports it.
+	$('next').hidden = !(s.commands || []).includes('next');
+	renderVolume(s.volume || 0, !!s.muted);
+	document.title = s.track ? s.track.artist + ' - ' + s.track.title : s.bundle;
+	renderNow();
+}
+
+function renderVolume(volume, muted) {
+	$('volume').value = volume;
+	$('mute').textContent = muted ? 'Unmute' : 'Mute';
+	$('mute').classList.toggle('on', muted);
+}
+
+function channelItem(id, title) {
+	const li = item(title, 'channel');
+	li.dataset.id = id;
+	li.onclick = () => api('POST', '/api/channel/' + id).then(() => showError(null), showError);
+	return li;
+}
+
+async function loadCatalog() {
+	const groups = await api('GET', '/api/catalog');
+	const root = $('catalog');
+	root.textContent = '';
+	for (const g of groups) {
+		const ul = document.createElement('ul');
+		ul.className = 'list';
+		for (const c of g.channels) {
+			ul.appendChild(channelItem(c.id, c.title));
+		}
+		if (groups.length === 1) {
+			root.appendChild(ul);
+			continue;
+		}
+		const details = document.createElement('details');
+		const summary = document.createElement('summary');
+		summary.textContent = g.title;
+		details.append(summary, ul);
+		root.appendChild(details);
+	}
+}
+
+async function loadFavorites() {
+	const favs = await api('GET', '/api/favorites');
+	const ul = $('favorites');
+	ul.textContent = '';
+	for (const f of favs) {
+		ul.appendChild(channelItem(f.channel, f.title));
+	}
+	if (favs.length === 0) {
+		ul.appendChild(item('No favorites yet', 'muted'));
+	}
+}
+
+async function loadHistory() {
+	const recs = await api('GET', '/api/history?limit=20');
+	const ul = $('history');
+	ul.textContent = '';
+	for (const r of recs) {
+		const at = new Date(r.time).toLocaleTimeString([], {hour: '2-digit', minute: '2-digit'});
+		ul.appendChild(item(at + '  ' + r.artist + ' - ' + r.title + (r.downloaded ? ' ⤓' : '')));
+	}
+}
+
+// Filter channels by the search query.
+function search() {
+	const q = $('search').value.toLowerCase();
+	for (const li of document.querySelectorAll('#catalog li.channel')) {
+		li.hidden = q !== '' && !li.textContent.toLowerCase().includes(q);
+	}
+	for (const d of document.querySelectorAll('#catalog details')) {
+		d.open = q !== '' && d.querySelector('li.channel:not([hidden])') !== null;
+	}
+}
+
+// Listen player events and refresh affected parts.
+function connect() {
+	const proto = location.protocol === 'https:' ? 'wss:' : 'ws:';
+	const ws = new WebSocket(proto + '//' + location.host + '/api/events' + (token ? '?token=' + encodeURIComponent(token) : ''));
+	ws.onopen = () => $('conn').classList.remove('off');
+	ws.onclose = () => {
+		$('conn').classList.add('off');
+		setTimeout(connect, 3000);
+	};
+	ws.onmessage = (msg) => {
+		const e = JSON.parse(msg.data);
+		switch (e.type) {
+		case 'track_start':
+		case 'status':
+			loadStatus().catch(showError);
+			break;
+		case 'volume':
+			renderVolume(e.volume, e.muted);
+			break;
+		case 'track_finish':
+		case 'download':
+			loadHistory().catch(showError);
+			break;
+		}
+	};
+}
+
+for (const btn of document.querySelectorAll('button[data-cmd]')) {
+	btn.onclick = () => api('POST', '/api/' + btn.dataset.cmd).then(() => showError(null), showError);
+}
+$('search').oninput = search;
+$('volume').onchange = () => api('POST', '/api/volume/' + $('volume').value).then(() => showError(null), showError);
+
+Promise.all([loadCatalog(), loadFavorites(), loadHistory()])
+	.then(loadStatus)
+	.catch(showError);
+connect();
+setInterval(renderTime, 1000);
@@ -0,0 +1,16 @@
+package conply
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed web
+var webFS embed.FS
+
+// Handler of web UI compiled into the binary.
+func webUI() http.Handler {
+	sub, _ := fs.Sub(webFS, "web")
+	return http.FileServer(http.FS(sub))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/koykov/conply"
+)
+
+const (
+	// Public credentials of AudioAddict apps, members API requires them besides the member's ones.
+	apiUser     = "ephemeron"
+	apiPassword = "dayeiph0ne@pp"
+)
+
+var (
+	ErrWrongCredentials = errors.New("wrong email or password")
+	ErrWrongListenKey   = errors.New("wrong listen key")
+)
+
+// AudioAddict member account. The same account works for all networks.
+type Account struct {
+	Email string `json:"email,omitempty"`
+	// Key to listen premium streams, it may be given instead of email and password.
+	ListenKey string `json:"listen_key"`
+	// Premium subscription is active.
+	Premium bool `json:"premium"`
+}
+
+// Get path to the account storage.
+func AccountPath() string {
+	path, _ := conply.GetConfigDir(Bundle)
+	return path + conply.PS + "account.json"
+}
+
+// Load the account. Returns nil if the member hasn't logged in.
+func AccountFromFile(path string) (*Account, error) {
+	if !conply.FileExists(path) {
+		return nil, nil
+	}
+	var acc Account
+	if err := conply.UnmarshalFile(path, &acc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// Save the account readable by the owner only, since listen key gives access to the paid subscription.
+func (a *Account) Save(path string) error {
+	return conply.MarshalPrivateFile(path, a, true)
+}
+
+// Check the account may listen premium streams.
+func (a *Account) IsPremium() bool {
+	return a != nil && a.Premium && len(a.ListenKey) > 0
+}
+
+// Log in to the network by email and password.
+// Password is used once to get the listen key, it's never stored.
+func Authenticate(st *Station, email, password string) (*Account, error) {
+	return authenticate(st, url.Values{"username": {email}, "password": {password}}, ErrWrongCredentials)
+}
+
+// Log in to the network by the listen key copied from the site.
+// The key is checked by the API, premium streams are enabled only if the API confirms the subscription.
+func AuthenticateKey(st *Station, key string) (*Account, error) {
+	return authenticate(st, url.Values{"listen_key": {key}}, ErrWrongListenKey)
+}
+
+// Send member credentials to the API and make the account of the member. Rejected credentials cause errWrong.
+func authenticate(st *Station, form url.Values, errWrong error) (*Account, error) {
+	req, err := http.NewRequest(http.MethodPost, st.API+"/"+st.Key+"/members/authenticate", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(apiUser, apiPassword)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized:
+		return nil, errWrong
+	case resp.StatusCode != http.StatusOK:
+		return nil, errors.New("authentication failed: " + resp.Status)
+	}
+	var member struct {
+		Email         string `json:"email"`
+		ListenKey     string `json:"listen_key"`
+		Subscriptions []struct {
+			Status string `json:"status"`
+		} `json:"subscriptions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&member); err != nil {
+		return nil, err
+	}
+	if len(member.ListenKey) == 0 {
+		return nil, errors.New("authentication failed: no listen key in response")
+	}
+	acc := Account{Email: member.Email, ListenKey: member.ListenKey}
+	for _, sub := range member.Subscriptions {
+		if sub.Status == "active" {
+			acc.Premium = true
+		}
+	}
+	return &acc, nil
+}
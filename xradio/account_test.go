@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// AudioAddict members API stub, it knows one member with the given listen key.
+func newTestMembersServer(t *testing.T, premium bool) *Station {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/di/members/authenticate" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if user, pass, _ := r.BasicAuth(); user != apiUser || pass != apiPassword {
+			t.Errorf("unexpected API credentials %q:%q", user, pass)
+		}
+		if (r.FormValue("username") != "member@example.com" || r.FormValue("password") != "secret") &&
+			r.FormValue("listen_key") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		status := "expired"
+		if premium {
+			status = "active"
+		}
+		_, _ = w.Write([]byte(`{"email":"member@example.com","listen_key":"key","subscriptions":[{"status":"` + status + `"}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return &Station{Key: "di", Station: srv.URL, API: srv.URL + "/api"}
+}
+
+func TestAuthenticate(t *testing.T) {
+	st := newTestMembersServer(t, true)
+	acc, err := Authenticate(st, "member@example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Email != "member@example.com" || acc.ListenKey != "key" || !acc.IsPremium() {
+		t.Errorf("unexpected account %+v", acc)
+	}
+	if _, err = Authenticate(st, "member@example.com", "wrong"); !errors.Is(err, ErrWrongCredentials) {
+		t.Errorf("error %v, want ErrWrongCredentials", err)
+	}
+}
+
+func TestAuthenticateFree(t *testing.T) {
+	acc, err := Authenticate(newTestMembersServer(t, false), "member@example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Free member has the listen key, but premium streams aren't available.
+	if acc.ListenKey != "key" || acc.IsPremium() {
+		t.Errorf("unexpected account %+v", acc)
+	}
+}
+
+func TestAuthenticateKey(t *testing.T) {
+	st := newTestMembersServer(t, true)
+	acc, err := AuthenticateKey(st, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.ListenKey != "key" || !acc.IsPremium() {
+		t.Errorf("unexpected account %+v", acc)
+	}
+	if _, err = AuthenticateKey(st, "wrong"); !errors.Is(err, ErrWrongListenKey) {
+		t.Errorf("error %v, want ErrWrongListenKey", err)
+	}
+}
+
+func TestAuthenticateError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	_, err := Authenticate(&Station{Key: "di", API: srv.URL}, "member@example.com", "secret")
+	if err == nil || errors.Is(err, ErrWrongCredentials) {
+		t.Errorf("error %v, want failed authentication", err)
+	}
+}
@@ -2,7 +2,8 @@ package main
 
 // Minimal application.
 type App struct {
-	Channels []AppChannel `json:"channels"`
+	Channels       []AppChannel `json:"channels"`
+	ChannelFilters []AppFilter  `json:"channel_filters"`
 }
 
 // Application channel.
@@ -11,3 +12,13 @@ type AppChannel struct {
 	Name string `json:"name"`
 	Slug string `json:"key"`
 }
+
+// Application channel filter, eg genre.
+type AppFilter struct {
+	Id       uint64 `json:"id"`
+	Name     string `json:"name"`
+	Key      string `json:"key"`
+	Channels []struct {
+		Id uint64 `json:"id"`
+	} `json:"channels"`
+}
@@ -31,6 +31,27 @@ func (cc *ChannelsCache) GetGroupById(id uint64) *ChannelCache {
 	return &defaultCC
 }
 
+// Check the channel exists.
+func (cc *ChannelsCache) Has(id uint64) bool {
+	for _, group := range *cc {
+		if group.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Get channels with given IDs in the same order, eg popular channels are ordered by popularity.
+func (cc *ChannelsCache) Subset(ids []uint64) ChannelsCache {
+	res := make(ChannelsCache, 0, len(ids))
+	for _, id := range ids {
+		if channel := cc.GetGroupById(id); channel.Id != 0 {
+			res = append(res, channel)
+		}
+	}
+	return res
+}
+
 // Build a human readable list of a channels.
 func (cc *ChannelsCache) PrettyPrint() string {
 	var res []string
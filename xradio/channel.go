@@ -1,6 +1,9 @@
 package main
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 // Rockradio channel.
 type Channel struct {
@@ -18,6 +21,15 @@ type CurrentlyPlaying struct {
 	Track Track `json:"track"`
 }
 
+// Get expiry of the chunk and its audio token.
+// Falls back to the chunk length if the site didn't report the expiry.
+func (c *Channel) Expires() time.Time {
+	if t, err := time.Parse(time.RFC3339, c.Expiry); err == nil {
+		return t
+	}
+	return time.Now().Add(time.Duration(c.Length) * time.Second)
+}
+
 // Build a human readable list of a channels.
 func (c *Channel) PrettyPrint() string {
 	list := make([]string, 0)
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/koykov/conply"
+)
+
+// Run the command instead of playing and exit.
+func runCommand(cmd string) {
+	if err := conply.PrepareEnv(Bundle); err != nil {
+		verbose.Fail("Error preparing the environment: ", err)
+		os.Exit(1)
+	}
+
+	var err error
+	switch cmd {
+	case "list":
+		err = list()
+	case "export":
+		err = export()
+	case "proxy":
+		err = serveProxy(*proxy)
+	case "history":
+		err = history()
+	case "login":
+		err = login()
+	case "logout":
+		err = logout()
+	default:
+		verbose.Failf("xradio: unknown command \"%s\"\nTry \"xradio --help\" for more information", cmd)
+		os.Exit(1)
+	}
+	if err != nil {
+		verbose.Fail(err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// Print channels of the station, optionally filtered by --match, or stations if no station is given.
+func list() error {
+	var buf bytes.Buffer
+	if ply.station == nil {
+		if err := stations.Write(&buf, *format); err != nil {
+			return err
+		}
+		return output(&buf)
+	}
+
+	if err := ply.LoadChannels(options["noCache"].(bool)); err != nil {
+		return err
+	}
+	cat := ply.Catalog()
+	if len(*match) > 0 {
+		cat = cat.Filter(*match)
+	}
+	if err := cat.Write(&buf, *format); err != nil {
+		return err
+	}
+	return output(&buf)
+}
+
+// Export channels of the station as a playlist.
+// Tracks require an audio token, so each entry points to the local stream proxy.
+func export() error {
+	if err := ply.LoadChannels(options["noCache"].(bool)); err != nil {
+		return err
+	}
+
+	pl := conply.Playlist{Title: ply.station.Key}
+	for _, channel := range ply.cache {
+		pl.Add(fmt.Sprintf("%s - %s", ply.station.Key, channel.Title), fmt.Sprintf("http://%s/%s/%d", *proxy, ply.station.Key, channel.Id))
+	}
+
+	f := *format
+	if len(f) == 0 {
+		f = conply.PlaylistFormat(*file)
+	}
+	var buf bytes.Buffer
+	if err := pl.Write(&buf, f); err != nil {
+		return err
+	}
+	return output(&buf)
+}
+
+// Export listening history. Only records of the station are exported if it's given.
+func history() error {
+	f, err := conply.NewHistoryFilter(*from, *to)
+	if err != nil {
+		return err
+	}
+	f.Channel = *match
+	// Channel ID or title given by -c works as well as --match.
+	if id, err := strconv.ParseUint(*channel, 10, 64); err == nil {
+		f.ChannelId = id
+	} else if len(f.Channel) == 0 {
+		f.Channel = *channel
+	}
+	if ply.station != nil {
+		f.Station = ply.station.Key
+	}
+
+	path, _ := conply.GetHistoryPath(Bundle)
+	recs, err := conply.HistoryFromFile(path)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := recs.Filter(f).Write(&buf, *format); err != nil {
+		return err
+	}
+	return output(&buf)
+}
+
+// Log in to AudioAddict by email and password or by the listen key given with --listen-key.
+// Email login requires the station to know the network API. The listen key is checked by the API of the given station
+// or of the first one, since the same account works for all networks.
+func login() error {
+	var acc *Account
+	if len(*lkey) > 0 {
+		st := ply.station
+		if st == nil {
+			st = &stations[0]
+		}
+		var err error
+		if acc, err = AuthenticateKey(st, *lkey); err != nil {
+			return err
+		}
+	} else {
+		if ply.station == nil {
+			return errors.New("station is required to log in by email, eg \"xradio di login\", or use --listen-key")
+		}
+		email, err := conply.ReadLine("Email: ")
+		if err != nil {
+			return err
+		}
+		password, err := conply.ReadSecret("Password: ")
+		if err != nil {
+			return err
+		}
+		if acc, err = Authenticate(ply.station, strings.TrimSpace(email), password); err != nil {
+			return err
+		}
+	}
+	if err := acc.Save(AccountPath()); err != nil {
+		return err
+	}
+	if acc.IsPremium() {
+		verbose.Info("Logged in, premium streams are enabled for all stations")
+	} else {
+		verbose.Warning("Logged in, but the account has no active premium subscription, so free streams will be used")
+	}
+	return nil
+}
+
+// Forget the account.
+func logout() error {
+	path := AccountPath()
+	if !conply.FileExists(path) {
+		verbose.Info("Not logged in")
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	verbose.Info("Logged out")
+	return nil
+}
+
+// Write command output to the file or stdout.
+func output(buf *bytes.Buffer) error {
+	if len(*file) == 0 {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := conply.FilePut(*file, buf.String()); err != nil {
+		return err
+	}
+	verbose.Debug1("Output has been written to ", *file)
+	return nil
+}
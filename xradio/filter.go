@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koykov/conply"
+)
+
+// Keys of filters shown before genres.
+var leadingFilters = []string{"popular", "new"}
+
+// Group of channels not covered by any filter.
+var otherFilter = ChannelFilter{Id: 0, Title: "Other", Key: "other"}
+
+// Channel filter of the station, eg genre, "Popular" or "New".
+type ChannelFilter struct {
+	Id       uint64   `json:"id"`
+	Title    string   `json:"title"`
+	Key      string   `json:"key"`
+	Channels []uint64 `json:"channels"`
+}
+
+// List of channel filters, it groups the channels in the picker.
+type ChannelFilters []*ChannelFilter
+
+// Build groups of channels from filters of the station.
+// Unknown channels and empty filters are dropped, "Popular" and "New" go first and channels without filter are
+// collected in "Other" group, so every channel is reachable.
+func NewChannelFilters(filters []AppFilter, cache ChannelsCache) ChannelFilters {
+	known := make(map[uint64]bool, len(cache))
+	for _, ch := range cache {
+		known[ch.Id] = true
+	}
+	covered := make(map[uint64]bool, len(cache))
+	res := make(ChannelFilters, 0, len(filters)+1)
+	for _, f := range filters {
+		group := ChannelFilter{Id: f.Id, Title: f.Name, Key: f.Key, Channels: make([]uint64, 0, len(f.Channels))}
+		for _, ch := range f.Channels {
+			if known[ch.Id] {
+				group.Channels = append(group.Channels, ch.Id)
+				covered[ch.Id] = true
+			}
+		}
+		if len(group.Channels) > 0 {
+			res = append(res, &group)
+		}
+	}
+	if len(res) == 0 {
+		return res
+	}
+
+	leading := make(ChannelFilters, 0, len(res))
+	for _, key := range leadingFilters {
+		for i, f := range res {
+			if f.Key == key {
+				leading = append(leading, f)
+				res = append(res[:i], res[i+1:]...)
+				break
+			}
+		}
+	}
+	res = append(leading, res...)
+
+	other := otherFilter
+	for _, ch := range cache {
+		if !covered[ch.Id] {
+			other.Channels = append(other.Channels, ch.Id)
+		}
+	}
+	if len(other.Channels) > 0 {
+		res = append(res, &other)
+	}
+	return res
+}
+
+// Get the filter by given ID.
+func (cf *ChannelFilters) GetById(id uint64) *ChannelFilter {
+	for _, f := range *cf {
+		if f.Id == id {
+			return f
+		}
+	}
+	return nil
+}
+
+// Get the filter by given title or key, case and punctuation are ignored.
+func (cf *ChannelFilters) GetByTitle(title string) *ChannelFilter {
+	slug := conply.Slug(title)
+	for _, f := range *cf {
+		if conply.Slug(f.Title) == slug || conply.Slug(f.Key) == slug {
+			return f
+		}
+	}
+	return nil
+}
+
+// Build a human readable list of a filters.
+func (cf *ChannelFilters) PrettyPrint() string {
+	var res []string
+	for _, f := range *cf {
+		res = append(res, fmt.Sprintf("%d - %s (%d)", f.Id, f.Title, len(f.Channels)))
+	}
+	return strings.Join(res, "\n")
+}
+
+// Load filters from the cache.
+func FiltersFromCache(path string) (ChannelFilters, error) {
+	cf := ChannelFilters{}
+	err := conply.UnmarshalFile(path, &cf)
+	return cf, err
+}
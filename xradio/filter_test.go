@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/koykov/conply"
+)
+
+func testFilters(t *testing.T) []AppFilter {
+	var filters []AppFilter
+	err := json.Unmarshal([]byte(`[
+		{"id": 1, "name": "Trance", "key": "trance", "channels": [{"id": 10}, {"id": 11}]},
+		{"id": 2, "name": "Popular", "key": "popular", "channels": [{"id": 11}, {"id": 20}]},
+		{"id": 3, "name": "House", "key": "house", "channels": [{"id": 20}, {"id": 99}]},
+		{"id": 4, "name": "Retired", "key": "retired", "channels": [{"id": 98}]},
+		{"id": 5, "name": "New", "key": "new", "channels": [{"id": 10}]}
+	]`), &filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filters
+}
+
+var testChannels = ChannelsCache{
+	{Id: 10, Title: "Vocal Trance", Slug: "vocaltrance"},
+	{Id: 11, Title: "Trance", Slug: "trance"},
+	{Id: 20, Title: "Deep House", Slug: "deephouse"},
+	{Id: 30, Title: "Ambient", Slug: "ambient"},
+}
+
+func TestNewChannelFilters(t *testing.T) {
+	cf := NewChannelFilters(testFilters(t), testChannels)
+	// Popular and New go first, empty filter is dropped and uncovered channels are collected in Other.
+	var got []string
+	for _, f := range cf {
+		slugs := make([]string, 0, len(f.Channels))
+		for _, id := range f.Channels {
+			slugs = append(slugs, conply.Slug(testChannels.GetGroupById(id).Title))
+		}
+		got = append(got, f.Key+":"+strings.Join(slugs, ","))
+	}
+	want := "popular:trance,deep-house new:vocal-trance trance:vocal-trance,trance house:deep-house other:ambient"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got filters %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestNewChannelFiltersEmpty(t *testing.T) {
+	// Station without filters is shown as the plain list of channels.
+	if cf := NewChannelFilters(nil, testChannels); len(cf) != 0 {
+		t.Errorf("got %d filters of station without filters", len(cf))
+	}
+}
+
+func TestChannelFiltersLookup(t *testing.T) {
+	cf := NewChannelFilters(testFilters(t), testChannels)
+	if f := cf.GetById(3); f == nil || f.Key != "house" {
+		t.Errorf("GetById(3) = %+v", f)
+	}
+	if f := cf.GetById(4); f != nil {
+		t.Errorf("empty filter %+v is found", f)
+	}
+	for _, title := range []string{"popular", "POPULAR", "Popular!"} {
+		if f := cf.GetByTitle(title); f == nil || f.Id != 2 {
+			t.Errorf("GetByTitle(%q) = %+v", title, f)
+		}
+	}
+	if f := cf.GetByTitle("other"); f == nil || f.Id != otherFilter.Id {
+		t.Errorf("GetByTitle(\"other\") = %+v", f)
+	}
+}
+
+func TestFiltersCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.json")
+	cf := NewChannelFilters(testFilters(t), testChannels)
+	if err := conply.MarshalFile(path, cf, false); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := FiltersFromCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.PrettyPrint() != cf.PrettyPrint() {
+		t.Errorf("cached filters:\n%s\nwant:\n%s", cached.PrettyPrint(), cf.PrettyPrint())
+	}
+}
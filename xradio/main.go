@@ -19,33 +19,44 @@ import (
 )
 
 var (
-	ply       *Player
-	options   conply.Options
-	keybind   *kb.Keybind
-	verbose   *v.Verbose
-	waitGroup *sync.WaitGroup
-
-	stations = Stations{
-		{"rock", "rockradio", "https://www.rockradio.com", "https://api.audioaddict.com/v1"},
-		{"rockradio", "rockradio", "https://www.rockradio.com", "https://api.audioaddict.com/v1"},
-		{"rockradio.com", "rockradio", "https://www.rockradio.com", "https://api.audioaddict.com/v1"},
-		{"jazz", "jazzradio", "https://www.jazzradio.com", "https://api.audioaddict.com/v1"},
-		{"jazzradio", "jazzradio", "https://www.jazzradio.com", "https://api.audioaddict.com/v1"},
-		{"jazzradio.com", "jazzradio", "https://www.jazzradio.com", "https://api.audioaddict.com/v1"},
-		{"classic", "classicradio", "https://www.classicalradio.com", "https://api.audioaddict.com/v1"},
-		{"classicradio", "classicradio", "https://www.classicalradio.com", "https://api.audioaddict.com/v1"},
-		{"classicradio.com", "classicradio", "https://www.classicalradio.com", "https://api.audioaddict.com/v1"},
-		{"tunes", "radiotunes", "https://www.radiotunes.com", "https://api.audioaddict.com/v1"},
-		{"radiotunes", "radiotunes", "https://www.radiotunes.com", "https://api.audioaddict.com/v1"},
-		{"radiotunes.com", "radiotunes", "https://www.radiotunes.com", "https://api.audioaddict.com/v1"},
-		{"zen", "zenradio", "https://www.zenradio.com", "https://api.audioaddict.com/v1"},
-		{"zenradio", "zenradio", "https://www.zenradio.com", "https://api.audioaddict.com/v1"},
-		{"zenradio.com", "zenradio", "https://www.zenradio.com", "https://api.audioaddict.com/v1"},
-	}
-	station *Station
+	ply         *Player
+	options     conply.Options
+	keybind     *kb.Keybind
+	mpris       *conply.MPRIS
+	control     *conply.Control
+	api         *conply.API
+	tui         *conply.TUI
+	evOutput    *conply.Output
+	termKeys    *conply.TermKeys
+	termHotkeys []*kb.Hotkey
+	verbose     *v.Verbose
+	waitGroup   *sync.WaitGroup
+	command     string
+
+	stations Stations
+	station  *Station
+
+	// Signals supported by xradio besides the common ones.
+	signals = []string{"sig-next", "sig-prev", "sig-replay", "sig-like", "sig-dislike"}
 
 	nc       = multiflag.Bools([]string{"no-cache", "nc"}, false, "Ignore cache data")
-	channel  = multiflag.Ints([]string{"channel", "c"}, 0, "Channel ID.")
+	channel  = multiflag.Strings([]string{"channel", "c"}, "", "Channel ID, title, slug or part of it, eg \"vocal trance\"")
+	fav      = multiflag.String("fav", "", "Favorite channel name.")
+	resume   = multiflag.Bool("resume", false, "Resume the last session instead of asking the channel.")
+	format   = multiflag.String("format", "", "Output format: table, json or tsv for list, m3u, pls or xspf for export, csv or json for history")
+	file     = multiflag.Strings([]string{"file", "f"}, "", "Write command or events output to the file or FIFO instead of stdout")
+	from     = multiflag.String("from", "", "History start date, eg 2006-01-02 or \"2006-01-02 15:04\"")
+	to       = multiflag.String("to", "", "History end date, eg 2006-01-02 or \"2006-01-02 15:04\"")
+	match    = multiflag.String("match", "", "Part of the channel title to filter list and history")
+	proxy    = multiflag.String("proxy", ProxyAddr, "Address of the local stream proxy")
+	httpAddr = multiflag.String("http", "", "Address of HTTP API, eg 127.0.0.1:8180. Disabled by default")
+	tuiMode  = multiflag.Bool("tui", false, "Full-screen terminal UI")
+	outFmt   = multiflag.String("output", "", "Machine-readable output of player events: jsonl or text, see -f")
+	sleepFor = multiflag.String("sleep", "", "Sleep timer, eg 45m. Playing fades out and stops when the time is over")
+	wakeAt   = multiflag.String("wake", "", "Wake up alarm, eg 07:30. Playing starts at the given time with volume ramp")
+	xfade    = multiflag.String("crossfade", "0s", "Crossfade between tracks, eg 3s. Tracks are played without gaps by default")
+	lkey     = multiflag.String("listen-key", "", "AudioAddict listen key to log in without email and password")
+	token    = multiflag.String("http-token", os.Getenv("CONPLY_HTTP_TOKEN"), "Token of HTTP API, required for non-loopback address")
 	verbose1 = multiflag.Bool("v", false, "Verbosity level 1")
 	verbose2 = multiflag.Bool("vv", false, "Verbosity level 2")
 	verbose3 = multiflag.Bool("vvv", false, "Verbosity level 3")
@@ -53,7 +64,17 @@ var (
 	sigStop = make(chan os.Signal)
 )
 
-func init() {
+// Parse the command line and prepare the player. Commands exit after running.
+// It isn't done in init, so tests of the package may run.
+func setup() {
+	// Load registry of stations.
+	if st, err := LoadStations(); err != nil {
+		v.NewVerbose(v.LevelFail).Fail("xradio: couldn't load stations: ", err)
+		os.Exit(1)
+	} else {
+		stations = st
+	}
+
 	// Check station alias.
 	if len(os.Args) < 2 {
 		v.NewVerbose(v.LevelFail).Fail("xradio: missing station operand\nTry \"xradio --help\" for more information")
@@ -70,24 +91,67 @@ func init() {
 
 	// Display help message on --help option and exit.
 	if alias == "--help" {
-		fmt.Println(`Usage: xradio [<station alias>|generate] [options]`)
-		fmt.Println(`Options:
-  -c                Channel ID (omit to see list of possible channels)
+		fmt.Println(`Usage: xradio [<station alias> [list|export|history|login]|list|generate|history|proxy|login|logout] [options]`)
+		fmt.Println(`Commands:
+  list              List channels of the station or stations if no station is given
+  export            Export channels of the station as a playlist
+  history           Export listening history of all stations or the given one
+  generate          Generate bash aliases for each station
+  proxy             Run the local stream proxy to play exported playlists
+  login             Log in to AudioAddict premium account, station is required unless --listen-key is given
+  logout            Forget AudioAddict account
+Options:
+  -c                Channel ID, title or slug, may be partial (omit to see list of possible channels)
+  --fav             Favorite channel name, station alias may be omitted
+  --resume          Resume the last session, station alias may be omitted
   --nc, --no-cache  Ignore cache data
+  --format          Output format: table, json or tsv for list, m3u, pls or xspf for export, csv or json for history
+  -f, --file        Write command or events output to the file or FIFO instead of stdout
+  --from, --to      History time range, eg 2006-01-02 or "2006-01-02 15:04"
+  --match           Part of the channel title to filter list and history
+  --proxy           Address of the local stream proxy (default ` + ProxyAddr + `)
+  --tui             Full-screen terminal UI
+  --output          Machine-readable output of player events: jsonl or text, see -f
+  --crossfade       Crossfade between tracks, eg 3s. Tracks are played without gaps by default
+  --sleep           Sleep timer, eg 45m. Playing fades out and stops when the time is over
+  --wake            Wake up alarm, eg 07:30. Playing starts at the given time with volume ramp
+  --listen-key      AudioAddict listen key to log in without email and password
+  --http            Address of HTTP API, eg 127.0.0.1:8180. Disabled by default
+  --http-token      Token of HTTP API, required for non-loopback address
   -v, -vv, -vvv     Display verbose information of levels 1-3`)
-		fmt.Println("\nStation aliases:")
+		fmt.Println("\nStations and their aliases (add more in " + StationsPath() + "):")
 		fmt.Println(stations.PrettyPrint())
 		os.Exit(0)
 	}
 
-	station = stations.Look(alias)
-	if station == nil {
-		v.NewVerbose(v.LevelFail).Failf("xradio: unknown station \"%s\"\nTry \"xradio --help\" for more information", alias)
-		os.Exit(1)
+	// Check proxy, history, list and account modes, they serve all stations.
+	if alias == "proxy" || alias == "history" || alias == "list" || alias == "login" || alias == "logout" {
+		command = alias
+	} else if alias == "--fav" || alias == "-fav" {
+		// Favorite channel knows its station.
+		station = favoriteStation(os.Args)
+		// There is no station alias, so duplicate program name to keep the args after omitting arg 1.
+		os.Args = append([]string{os.Args[0]}, os.Args...)
+	} else if alias == "--resume" || alias == "-resume" {
+		// Last session knows its station.
+		station = sessionStation()
+		os.Args = append([]string{os.Args[0]}, os.Args...)
+	} else {
+		station = stations.Look(alias)
+		if station == nil {
+			v.NewVerbose(v.LevelFail).Failf("xradio: unknown station \"%s\"\nTry \"xradio --help\" for more information", alias)
+			os.Exit(1)
+		}
 	}
 	// Omit arg 1 (station name) to parse flags properly.
 	os.Args = os.Args[1:]
 
+	// Check station command.
+	if station != nil && len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+		os.Args = os.Args[1:]
+	}
+
 	// Parse flags.
 	multiflag.Parse()
 
@@ -108,12 +172,60 @@ func init() {
 	// Cache control.
 	options["noCache"] = *nc
 	// Predefined channel.
-	options["channel"] = uint64(*channel)
+	options["channel"] = *channel
+	// Favorite channel.
+	options["favorite"] = *fav
+	// Resume the last session.
+	options["resume"] = *resume
+	// Sleep timer.
+	options["sleep"] = time.Duration(0)
+	if len(*sleepFor) > 0 {
+		d, err := time.ParseDuration(*sleepFor)
+		if err != nil || d <= 0 {
+			v.NewVerbose(v.LevelFail).Failf("xradio: invalid sleep timer \"%s\"\nTry \"xradio --help\" for more information", *sleepFor)
+			os.Exit(1)
+		}
+		options["sleep"] = d
+	}
+	// Wake up alarm.
+	options["wake"] = time.Time{}
+	if len(*wakeAt) > 0 {
+		t, err := conply.NextClock(*wakeAt, time.Now())
+		if err != nil {
+			v.NewVerbose(v.LevelFail).Failf("xradio: invalid wake up time \"%s\"\nTry \"xradio --help\" for more information", *wakeAt)
+			os.Exit(1)
+		}
+		options["wake"] = t
+	}
+	// Crossfade between tracks.
+	if d, err := time.ParseDuration(*xfade); err != nil || d < 0 {
+		v.NewVerbose(v.LevelFail).Failf("xradio: invalid crossfade \"%s\"\nTry \"xradio --help\" for more information", *xfade)
+		os.Exit(1)
+	} else {
+		options["crossfade"] = d
+	}
 
 	verbose = v.NewVerbose(options["verboseLevel"].(v.VerbosityLevel))
 
 	// Init the player.
 	ply = NewPlayer(verbose, options)
+
+	// Commands doesn't need neither VLC nor hotkeys.
+	if len(command) > 0 {
+		runCommand(command)
+	}
+
+	// Write events for status bars and scripts.
+	if len(*outFmt) > 0 {
+		var err error
+		if evOutput, err = conply.NewOutput(*outFmt, *file); err != nil {
+			verbose.Fail("Couldn't open events output: ", err)
+			os.Exit(1)
+		}
+		ply.events.Subscribe(evOutput.Listen)
+	}
+
+	verbose.Info(Bundle + " " + Version)
 	verbose.Debug1f("Init options:\n%s", options.PrettyPrint())
 	if err := ply.Init(); err != nil {
 		verbose.Fail("Initialization failed due to error: ", err)
@@ -124,20 +236,45 @@ func init() {
 
 	// Init keybinding.
 	hkPath, _ := conply.GetHKPath(Bundle)
-	keybind = kb.NewKeybind(ply)
-	if err := keybind.LoadFromFile(hkPath); err != nil {
+	hotkeys, err := conply.LoadHotkeys(hkPath)
+	if err != nil {
 		verbose.Fail("Hotkeys will unavailable during this session due to error: ", err)
 	}
+	xkeys, tkeys := conply.SplitHotkeys(hotkeys)
+	if len(tkeys) == 0 {
+		// Config was created before terminal keys appeared.
+		_, tkeys = conply.SplitHotkeys(defaultHotkeys)
+	}
+	termHotkeys = tkeys
+	keybind = kb.NewKeybind(ply)
+	keybind.SetHotkeys(xkeys)
 	if err := keybind.Init(); err != nil {
-		verbose.Fail("Hotkeys will unavailable during this session due to error: ", err)
+		keybind = nil
+		verbose.Warning("X hotkeys will unavailable during this session, terminal keys will be used instead: ", err)
+	}
+
+	// Expose the player to desktop environment.
+	if mpris, err = conply.NewMPRIS(Bundle, ply, &ply.np, signals...); err != nil {
+		verbose.Warning("MPRIS interface will unavailable during this session due to error: ", err)
+	} else {
+		ply.events.Subscribe(mpris.Listen)
+	}
+
+	// Listen commands from scripts and other terminals.
+	if control, err = conply.NewControl(Bundle, ply, &ply.np); err != nil {
+		verbose.Warning("Control socket will unavailable during this session due to error: ", err)
 	}
 
 	waitGroup = &sync.WaitGroup{}
 }
 
 func main() {
+	setup()
+
 	// Wait for hotkeys.
-	go keybind.Wait()
+	if keybind != nil {
+		go keybind.Wait()
+	}
 
 	// Register cleanup callback.
 	signal.Notify(sigStop, os.Interrupt, syscall.SIGTERM)
@@ -153,15 +290,14 @@ func main() {
 	}()
 
 	// Get audio token.
+	session := NewSession(ply)
 	waitGroup.Add(1)
 	go func() {
 		defer waitGroup.Done()
 		verbose.Debug1("Get audio token")
-		if err := ply.RetrieveAToken(); err != nil {
+		if err := session.Refresh(); err != nil {
 			verbose.Fail("Couldn't retrieve audio token: ", err)
 			_ = conply.Halt(1)
-		} else {
-			verbose.Debug2("Audio token retrieved: ", ply.atoken)
 		}
 	}()
 
@@ -171,98 +307,145 @@ func main() {
 		defer waitGroup.Done()
 		verbose.Debug1("Get channels")
 
-		// Check cache first.
-		cacheFile, _ := conply.GetChannelsPathWS(Bundle, ply.station.Key)
-		verbose.Debug2("Look for channels in cache ", cacheFile)
-		regenRequire := !conply.FileExists(cacheFile) || conply.FileAge(cacheFile) > CacheExpire || options["noCache"].(bool)
-		if regenRequire {
-			verbose.Debug2(`Cache is invalid or expired or "--no-cache" options has applied, try to regenerate it`)
-			verbose.Debug2("Looking for channels list in remote site")
-			if err := ply.RetrieveChannels(); err != nil {
-				verbose.Fail("Couldn't retrieve channels from remote site: ", err)
-				_ = conply.Halt(1)
-			} else {
-				verbose.Debug2f("Channels list has been retrieved from remote site, total retrieved: %d", len(ply.cache))
-				if err := conply.MarshalFile(cacheFile, ply.cache, true); err != nil {
-					verbose.Fail("Writing cache error: ", err)
-				} else {
-					verbose.Debug3("Channels list has been saved in cache file ", cacheFile)
-				}
-			}
-		} else {
-			var err error
-			verbose.Debug2("Get channels from cache")
-			if ply.cache, err = ChannelsFromCache(cacheFile); err != nil {
-				verbose.Fail("Cache reading problem: ", err)
-				_ = conply.Halt(1)
-			} else {
-				verbose.Debug3f("Channels list has been retrieved from cache, total retrieved: %d", len(ply.cache))
-			}
+		if err := ply.LoadChannels(options["noCache"].(bool)); err != nil {
+			verbose.Fail("Couldn't get channels: ", err)
+			_ = conply.Halt(1)
 		}
 	}()
 
 	// Wait for retrieving token and channels.
 	waitGroup.Wait()
 
-	// Ask channel ID.
+	// Start HTTP API.
+	if len(*httpAddr) > 0 {
+		var err error
+		if api, err = conply.NewAPI(Bundle, *httpAddr, *token, ply, &ply.np, signals...); err != nil {
+			verbose.Warning("HTTP API will unavailable during this session due to error: ", err)
+		} else {
+			ply.events.Subscribe(api.Listen)
+			verbose.Infof("HTTP API is listening on http://%s", *httpAddr)
+		}
+	}
+
+	// Start terminal UI.
+	if *tuiMode {
+		var err error
+		if tui, err = conply.NewTUI(Bundle, ply, &ply.np, signals...); err != nil {
+			verbose.Warning("Terminal UI will unavailable during this session due to error: ", err)
+		} else {
+			ply.events.Subscribe(tui.Listen)
+		}
+	}
+
+	// Look for predefined channel.
+	if query := options["channel"].(string); len(query) > 0 {
+		if m, err := ply.Catalog().Lookup(query); err != nil {
+			failLookup(query, err)
+			verbose.Fail("Exiting.")
+			_ = conply.Halt(1)
+		} else {
+			ply.chIdx = m.Id
+		}
+	}
+
+	// Check favorite channel.
+	if name := options["favorite"].(string); len(name) > 0 {
+		fav := ply.favs.Get(name)
+		if fav == nil || fav.Station != ply.station.Key {
+			verbose.Failf("Favorite \"%s\" doesn't exists. Exiting.", name)
+			_ = conply.Halt(1)
+		} else {
+			ply.chIdx = fav.Channel
+		}
+	}
+
+	// Check the last session.
+	if options["resume"].(bool) && ply.chIdx == 0 {
+		path, _ := conply.GetSessionPath(Bundle)
+		if session, err := conply.SessionFromFile(path); err != nil {
+			verbose.Warning("Couldn't resume the last session: ", err)
+		} else if session.Station != ply.station.Key {
+			verbose.Warningf("The last session was on station %s, ask channel instead", session.Station)
+		} else {
+			verbose.Debug1f("Resume the last session: %#v", session)
+			ply.chIdx = session.Channel
+			if session.Paused {
+				verbose.Info("Session is resumed in paused state")
+				ply.setStatus(conply.StatusPause)
+			}
+		}
+	}
+
+	// Channel of the last session or favorite may be removed from the station since then.
+	if ply.chIdx > 0 && !ply.cache.Has(ply.chIdx) {
+		verbose.Warningf("Channel %d isn't found on %s, choose another one", ply.chIdx, ply.station.Key)
+		ply.chIdx = 0
+	}
+
+	// Terminal UI has own channels browser.
+	if tui != nil && ply.chIdx == 0 {
+		verbose.Info("Choose the channel to play")
+		ply.chIdx = <-ply.switchTo
+	}
+
+	// Ask group and channel ID.
 	if ply.chIdx > 0 {
 		verbose.Debug1f("Channel predefined: %d", ply.chIdx)
 	} else {
-		verbose.Debug1("Ask for channel to play")
 		reader := bufio.NewReader(os.Stdin)
-		verbose.Infof("Channel ID:\n%s", ply.cache.PrettyPrint())
-		attempts := 0
-		for {
-			fmt.Print("Channel: ")
-			fail := false
-			attempts++
-			chIdx, err := reader.ReadString('\n')
-			if err != nil {
-				fail = true
-				verbose.Fail("Couldn't receive channel ID from stdin: ", err)
-			}
-			verbose.Debug2f("Raw value you specified: %#v", chIdx)
-			ply.chIdx, err = strconv.ParseUint(strings.Trim(chIdx, "\n"), 10, 64)
-			if err != nil {
-				fail = true
-				verbose.Fail("Couldn't convert value to channel ID: ", err)
-			} else {
-				verbose.Debug3f("Channel ID from raw value: %v", ply.chIdx)
-			}
-			channel := ply.cache.GetGroupById(ply.chIdx)
-			if channel == nil {
-				fail = true
-				verbose.Fail("Channel ID you specified doesn't exists, try again")
-			}
-			if !fail {
-				break
-			}
-			if fail && attempts >= 3 {
-				verbose.Failf("Oops, you've specified wrong channel ID %d times. Exiting.", attempts)
-				_ = conply.Halt(1)
-				break
-			}
+		favs := ply.favs.Station(ply.station.Key)
+		if len(favs) > 0 {
+			verbose.Infof("Favorites:\n%s", favs.PrettyPrint())
+		}
+		// Stations without filters have flat list of channels.
+		var group *ChannelFilter
+		if len(ply.groups) > 0 {
+			group = askGroup(reader, favs)
+		}
+		if ply.chIdx == 0 {
+			askChannel(reader, favs, group)
+		}
+	}
+	// Use terminal keys if X server is unavailable. Stdin is free since the channel has chosen.
+	if keybind == nil && tui == nil {
+		var err error
+		if termKeys, err = conply.NewTermKeys(ply, termHotkeys); err != nil {
+			verbose.Fail("Hotkeys will unavailable during this session due to error: ", err)
+		} else {
+			go termKeys.Wait()
 		}
 	}
+
 	channel := ply.cache.GetGroupById(ply.chIdx)
 	verbose.Infof("Playing: %s", channel.Title)
 
-	var (
-		attempts     = 0
-		RefreshToken = func(ply *Player) {
-			if err := ply.RetrieveAToken(); err != nil {
-				verbose.Fail("Couldn't retrieve audio token: ", err)
-				_ = conply.Halt(1)
-			} else {
-				verbose.Debug2("Audio token retrieved: ", ply.atoken)
-			}
+	attempts := 0
+
+	// Idle until the wake up time, neither tracks nor tokens are requested meanwhile.
+	if wake := options["wake"].(time.Time); !wake.IsZero() {
+		verbose.Infof("Playing starts at %s", wake.Format("15:04"))
+		conply.WaitUntil(wake)
+		ply.wakeRamp = conply.WakeFadeDuration
+		// Token has been expired during the night.
+		if err := session.Refresh(); err != nil {
+			verbose.Fail("Couldn't retrieve audio token: ", err)
 		}
-	)
+	}
+	if d := options["sleep"].(time.Duration); d > 0 {
+		ply.SetSleep(d)
+	}
+
+	// The first track of the chunk starts from scratch, others are preloaded by previous ones.
+	// The first track of the next chunk is preloaded as well if the chunk was prefetched in time.
+	preloaded, crossfade := false, time.Duration(0)
+Loop:
 	for {
 		// Try to get chunk of tracks.
 		attempts++
-		err := ply.RetrieveTracks()
+		channel, err := session.Next()
 		if err != nil {
+			// Nothing is preloaded since the chunk is missing.
+			preloaded = false
 			if attempts >= 3 {
 				// Attempts limit has been exceeded, stop executing.
 				verbose.Failf("%d failed attempts when retrieve the tracks. Exiting.", attempts)
@@ -274,59 +457,125 @@ func main() {
 			continue
 		}
 		attempts = 0
-		// Register a tick to refresh the audio token.
-		ply.ticks["token"] = time.After(time.Duration(ply.channel.Length-5) * time.Second)
+		ply.channel = channel
 
 		verbose.Debug1f("%d tracks retrieved", len(ply.channel.Tracks))
 		verbose.Debug2f("Tracks:\n%s", ply.channel.PrettyPrint())
-		verbose.Debug1f("Next query after %s", conply.FormatTime(uint64(ply.channel.Length)))
 
-		for i, track := range ply.channel.Tracks {
-			// Register a tick at the end of the current track.
-			ply.ticks["track"] = time.After(time.Duration(track.Content.Length) * time.Second)
+		// Tracks are taken from the chunk one by one, previous and replayed tracks are taken from the played history.
+		replaying := false
+		for i := 0; i < len(ply.channel.Tracks); {
+			if !replaying {
+				ply.played.Push(ply.channel.Tracks[i])
+			}
+			track := *ply.played.Current()
 			verbose.Info(track.ComposeTitle())
 
 			// Play the track.
 			ply.SetTrack(&track)
-			go func(ply *Player) {
-				err := ply.Play()
-				if err != nil {
-					verbose.Fail("Play failed due to error: ", err)
+			ply.setUpcoming(append(ply.played.Ahead(), ply.channel.Tracks[i+1:]...))
+			ply.startTrack()
+			if preloaded {
+				err = ply.PlayNext(crossfade)
+			} else {
+				err = ply.Play()
+			}
+			if err != nil {
+				verbose.Fail("Play failed due to error: ", err)
+			}
+			// Preload the next track to play it without gap.
+			// The last track of the chunk waits for the next chunk instead, history tracks aren't preloaded.
+			last := i == len(ply.channel.Tracks)-1
+			preloaded = !replaying && !last
+			switch {
+			case preloaded:
+				if err := ply.Preload(&ply.channel.Tracks[i+1]); err != nil {
+					verbose.Warning("Couldn't preload the next track: ", err)
 				}
-			}(ply)
+			case last:
+				verbose.Debug1("Prefetch the next chunk of tracks")
+				session.Prefetch()
+			}
 
-			finishTrack, nextTrack := false, false
+			finishTrack, nextTrack, prevTrack, replayTrack, switchChannel := false, false, false, false, false
 			for {
 				select {
-				case <-ply.ticks["track"]:
+				case <-ply.vlc.Ending():
 					// Caught a signal of end of the current track.
 					finishTrack = true
 				case <-ply.signals["next"]:
 					// Caught a signal to switch to the next track.
 					nextTrack = true
-					if i == len(ply.channel.Tracks)-1 {
-						verbose.Debug1("Force refresh the Audio token due to you skipped the last track in the chunk")
-						// We're skip the last track in the chunk. Need to have fresh audio token right now.
-						RefreshToken(ply)
+				case <-ply.signals["prev"]:
+					// Caught a signal to return to the previous track.
+					prevTrack = true
+				case <-ply.signals["replay"]:
+					// Caught a signal to play the current track from the start.
+					replayTrack = true
+				case cid := <-ply.switchTo:
+					// Caught a signal to switch the channel.
+					switchChannel = true
+					ply.chIdx = cid
+				case next := <-session.Ready():
+					// The next chunk has been prefetched, preload its first track to play it without gap.
+					// History track is followed by other one, so the chunk waits.
+					if len(next.Tracks) > 0 && last && !replaying {
+						if err := ply.Preload(&next.Tracks[0]); err != nil {
+							verbose.Warning("Couldn't preload the next track: ", err)
+						} else {
+							preloaded = true
+						}
 					}
-				case <-ply.ticks["token"]:
-					// It's time to get the fresh audio token.
-					go func(ply *Player) {
-						verbose.Debug1("Audio token expired, try to get fresh one")
-						RefreshToken(ply)
-					}(ply)
+				}
+				if switchChannel {
+					ply.vlc.DropPreloaded()
+					ply.stopTrack(true)
+					ply.finishTrack(true)
+					preloaded = false
+					session.Drop()
+					// Tracks of other channel can't be returned to.
+					ply.played.Reset()
+					ply.dropSignals()
+					verbose.Infof("Playing: %s", ply.cache.GetGroupById(ply.chIdx).Title)
+					// Tracks of the new channel need fresh audio token.
+					if err := session.Refresh(); err != nil {
+						verbose.Fail("Couldn't retrieve audio token: ", err)
+					}
+					continue Loop
+				}
+				if prevTrack || replayTrack {
+					if prevTrack && !ply.played.Back() {
+						verbose.Info("There is no previous track, play the current one from the start")
+					}
+					// Preloaded track isn't the next one anymore.
+					ply.vlc.DropPreloaded()
+					ply.stopTrack(true)
+					ply.finishTrack(true)
+					preloaded, replaying = false, true
+					verbose.Debug1("Return to the played track")
+					break
 				}
 				if finishTrack || nextTrack {
-					// Stop the playing of current track and restore the status.
-					status := ply.status
-					_ = ply.Stop()
-					ply.status = status
+					if !preloaded {
+						// The chunk is over, the next one starts from scratch.
+						ply.stopTrack(nextTrack)
+					}
+					// Skipped track is faded out quickly.
+					crossfade = ply.crossfade
+					if nextTrack {
+						crossfade = conply.FadeDuration
+					}
+					ply.finishTrack(nextTrack)
 					switch {
 					case finishTrack:
 						verbose.Debug1("Current track finished, shift to the next")
 					case nextTrack:
 						verbose.Debug1("Current track skipped, shift to the next")
 					}
+					// Tracks played before the current one are played again until the history is over.
+					if replaying = ply.played.Forward(); !replaying {
+						i++
+					}
 					break
 				}
 			}
@@ -334,16 +583,143 @@ func main() {
 	}
 }
 
+// Ask group of channels to play. Favorite name and channel title are accepted as well, the channel is chosen then.
+func askGroup(reader *bufio.Reader, favs conply.Favorites) *ChannelFilter {
+	verbose.Debug1("Ask for group to play")
+	verbose.Infof("Group ID, title, favorite name or channel title:\n%s", ply.groups.PrettyPrint())
+	for attempts := 1; ; attempts++ {
+		fmt.Print("Group: ")
+		raw, err := reader.ReadString('\n')
+		if err != nil {
+			verbose.Fail("Couldn't receive group ID from stdin: ", err)
+		}
+		verbose.Debug2f("Raw value you specified: %#v", raw)
+		raw = strings.Trim(raw, "\n")
+		star := strings.HasSuffix(raw, "*")
+		raw = strings.TrimSuffix(raw, "*")
+		if fav := favs.Get(raw); fav != nil {
+			verbose.Debug3f("Favorite from raw value: %s", fav.Name)
+			ply.chIdx = fav.Channel
+			return nil
+		}
+		if id, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			if group := ply.groups.GetById(id); group != nil {
+				verbose.Debug3f("Group from raw value: %s", group.Title)
+				return group
+			}
+			verbose.Fail("Group ID you specified doesn't exists, try again")
+		} else if group := ply.groups.GetByTitle(raw); group != nil {
+			verbose.Debug3f("Group from raw value: %s", group.Title)
+			return group
+		} else if m, err := ply.Catalog().Lookup(raw); err != nil {
+			failLookup(raw, err)
+		} else {
+			verbose.Debug3f("Channel from raw value: %s", m)
+			ply.chIdx = m.Id
+			if star {
+				if err := ply.ToggleFavorite(); err != nil {
+					verbose.Fail("Couldn't save favorites: ", err)
+				}
+			}
+			return nil
+		}
+		if attempts >= 3 {
+			verbose.Failf("Oops, you've specified wrong group %d times. Exiting.", attempts)
+			_ = conply.Halt(1)
+			return nil
+		}
+	}
+}
+
+// Ask channel to play among channels of the group or all channels of the station if the group isn't given.
+func askChannel(reader *bufio.Reader, favs conply.Favorites, group *ChannelFilter) {
+	verbose.Debug1("Ask for channel to play")
+	channels, cat := ply.cache, ply.Catalog()
+	if group != nil {
+		channels = ply.cache.Subset(group.Channels)
+		cat = conply.Catalog{ply.catalogGroup(group.Id, group.Title, channels)}
+	}
+	verbose.Infof("Channel ID, title or favorite name (add * to star the channel, eg 42*):\n%s", channels.PrettyPrint())
+	for attempts := 1; ; attempts++ {
+		fmt.Print("Channel: ")
+		raw, err := reader.ReadString('\n')
+		if err != nil {
+			verbose.Fail("Couldn't receive channel ID from stdin: ", err)
+		}
+		verbose.Debug2f("Raw value you specified: %#v", raw)
+		raw = strings.Trim(raw, "\n")
+		star := strings.HasSuffix(raw, "*")
+		raw = strings.TrimSuffix(raw, "*")
+		if fav := favs.Get(raw); fav != nil {
+			verbose.Debug3f("Favorite from raw value: %s", fav.Name)
+			ply.chIdx = fav.Channel
+			return
+		}
+		if m, err := cat.Lookup(raw); err != nil {
+			failLookup(raw, err)
+		} else {
+			verbose.Debug3f("Channel from raw value: %s", m)
+			ply.chIdx = m.Id
+			if star {
+				if err := ply.ToggleFavorite(); err != nil {
+					verbose.Fail("Couldn't save favorites: ", err)
+				}
+			}
+			return
+		}
+		if attempts >= 3 {
+			verbose.Failf("Oops, you've specified wrong channel %d times. Exiting.", attempts)
+			_ = conply.Halt(1)
+			return
+		}
+	}
+}
+
+// Get station of favorite channel given in args like "xradio --fav <name>".
+func favoriteStation(args []string) *Station {
+	if len(args) < 3 {
+		v.NewVerbose(v.LevelFail).Fail("xradio: missing favorite name\nTry \"xradio --help\" for more information")
+		os.Exit(1)
+	}
+	favPath, _ := conply.GetFavoritesPath(Bundle)
+	favs, err := conply.FavoritesFromFile(favPath)
+	if err != nil {
+		v.NewVerbose(v.LevelFail).Fail("xradio: favorites reading problem: ", err)
+		os.Exit(1)
+	}
+	fav := favs.Get(args[2])
+	if fav == nil {
+		v.NewVerbose(v.LevelFail).Failf("xradio: unknown favorite \"%s\"", args[2])
+		os.Exit(1)
+	}
+	st := stations.Look(fav.Station)
+	if st == nil {
+		v.NewVerbose(v.LevelFail).Failf("xradio: unknown station \"%s\" of favorite \"%s\"", fav.Station, fav.Name)
+		os.Exit(1)
+	}
+	return st
+}
+
+// Get station of the last session for "xradio --resume" call.
+func sessionStation() *Station {
+	path, _ := conply.GetSessionPath(Bundle)
+	session, err := conply.SessionFromFile(path)
+	if err != nil {
+		v.NewVerbose(v.LevelFail).Fail("xradio: couldn't resume the last session: ", err)
+		os.Exit(1)
+	}
+	st := stations.Look(session.Station)
+	if st == nil {
+		v.NewVerbose(v.LevelFail).Failf("xradio: unknown station \"%s\" of the last session", session.Station)
+		os.Exit(1)
+	}
+	return st
+}
+
 // Generate bash aliases for each station.
 func generate() {
 	verbose = v.NewVerbose(v.LevelInfo)
-	processed := map[string]bool{}
 	for _, st := range stations {
-		if _, ok := processed[st.Key]; ok {
-			continue
-		}
-		processed[st.Key] = true
-
 		body := `#!/bin/bash
 
 if ! command -v xradio &> /dev/null
@@ -366,3 +742,12 @@ fi`
 		verbose.Infof("generate alias %s for station %s", st.Key, st.Station)
 	}
 }
+
+// Report failed channel lookup.
+func failLookup(query string, err error) {
+	if err == conply.ErrUnknownChannel {
+		verbose.Failf("Channel \"%s\" doesn't exists", query)
+	} else {
+		verbose.Fail(err)
+	}
+}
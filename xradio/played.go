@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Limit of tracks kept in played history.
+	PlayedLimit = 100
+	// Free listeners may skip a few tracks per hour, premium members skip without limits.
+	FreeSkips  = 6
+	SkipWindow = time.Hour
+)
+
+// History of tracks played during the session, it's used to return to previous tracks.
+// The history keeps tracks of the current channel across chunks.
+type Played struct {
+	tracks []Track
+	// Position of the current track, it's less than the last one while returning to previous tracks.
+	pos int
+}
+
+// Add the track the player starts and make it current.
+func (p *Played) Push(track Track) {
+	p.tracks = append(p.tracks, track)
+	if len(p.tracks) > PlayedLimit {
+		p.tracks = append(p.tracks[:0], p.tracks[len(p.tracks)-PlayedLimit:]...)
+	}
+	p.pos = len(p.tracks) - 1
+}
+
+// Get the current track.
+func (p *Played) Current() *Track {
+	if len(p.tracks) == 0 {
+		return nil
+	}
+	return &p.tracks[p.pos]
+}
+
+// Move to the previous track. Returns false if the current track is the first one.
+func (p *Played) Back() bool {
+	if p.pos == 0 {
+		return false
+	}
+	p.pos--
+	return true
+}
+
+// Move to the next played track. Returns false if the current track is the last one, so the chunk goes on.
+func (p *Played) Forward() bool {
+	if p.pos >= len(p.tracks)-1 {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+// Get tracks to play again after the current one.
+func (p *Played) Ahead() []Track {
+	if len(p.tracks) == 0 {
+		return nil
+	}
+	return append([]Track(nil), p.tracks[p.pos+1:]...)
+}
+
+// Forget played tracks, eg when the channel is switched.
+func (p *Played) Reset() {
+	p.tracks, p.pos = p.tracks[:0], 0
+}
+
+// Skips made during the sliding window, the service refuses to skip when the limit is reached.
+type SkipBudget struct {
+	limit  int
+	window time.Duration
+	mux    sync.Mutex
+	skips  []time.Time
+}
+
+// The constructor. Zero limit means unlimited skips.
+func NewSkipBudget(limit int, window time.Duration) *SkipBudget {
+	return &SkipBudget{limit: limit, window: window}
+}
+
+// Spend a skip. Returns false if the limit is reached.
+func (b *SkipBudget) Take() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.limit == 0 {
+		return true
+	}
+	b.expire()
+	if len(b.skips) >= b.limit {
+		return false
+	}
+	b.skips = append(b.skips, time.Now())
+	return true
+}
+
+// Get the number of skips left, -1 means unlimited.
+func (b *SkipBudget) Left() int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.limit == 0 {
+		return -1
+	}
+	b.expire()
+	return b.limit - len(b.skips)
+}
+
+// Get the time the next skip becomes available, zero if it's available now.
+func (b *SkipBudget) Available() time.Time {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.expire()
+	if b.limit == 0 || len(b.skips) < b.limit {
+		return time.Time{}
+	}
+	return b.skips[0].Add(b.window)
+}
+
+// Forget skips out of the window.
+func (b *SkipBudget) expire() {
+	now, i := time.Now(), 0
+	for i < len(b.skips) && now.Sub(b.skips[i]) >= b.window {
+		i++
+	}
+	b.skips = b.skips[i:]
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlayed(t *testing.T) {
+	var p Played
+	if p.Current() != nil || p.Back() || p.Forward() || p.Ahead() != nil {
+		t.Fatal("empty history has tracks")
+	}
+	for id := uint64(1); id <= 3; id++ {
+		p.Push(Track{Id: id})
+	}
+	if cur := p.Current(); cur == nil || cur.Id != 3 || p.Forward() {
+		t.Fatalf("current track %+v, want the last one", cur)
+	}
+
+	// Return to the first track and go forward again.
+	if !p.Back() || !p.Back() || p.Back() {
+		t.Fatal("unexpected moves back")
+	}
+	if cur := p.Current(); cur.Id != 1 {
+		t.Errorf("current track %d, want 1", cur.Id)
+	}
+	if ahead := p.Ahead(); len(ahead) != 2 || ahead[0].Id != 2 || ahead[1].Id != 3 {
+		t.Errorf("unexpected tracks ahead %+v", ahead)
+	}
+	if !p.Forward() || p.Current().Id != 2 {
+		t.Errorf("current track %d, want 2", p.Current().Id)
+	}
+
+	// New track becomes current.
+	p.Push(Track{Id: 4})
+	if cur := p.Current(); cur.Id != 4 || len(p.Ahead()) != 0 {
+		t.Errorf("current track %d, want 4", cur.Id)
+	}
+
+	p.Reset()
+	if p.Current() != nil || p.Back() {
+		t.Error("history isn't reset")
+	}
+}
+
+func TestPlayedLimit(t *testing.T) {
+	var p Played
+	for id := uint64(1); id <= PlayedLimit+10; id++ {
+		p.Push(Track{Id: id})
+	}
+	// The oldest tracks are dropped.
+	if len(p.tracks) != PlayedLimit || p.tracks[0].Id != 11 || p.Current().Id != PlayedLimit+10 {
+		t.Fatalf("got %d tracks from %d to %d", len(p.tracks), p.tracks[0].Id, p.Current().Id)
+	}
+	n := 0
+	for p.Back() {
+		n++
+	}
+	if n != PlayedLimit-1 || p.Current().Id != 11 {
+		t.Errorf("moved back %d times to track %d", n, p.Current().Id)
+	}
+}
+
+func TestSkipBudget(t *testing.T) {
+	const window = 100 * time.Millisecond
+	b := NewSkipBudget(2, window)
+	if !b.Take() || !b.Take() {
+		t.Fatal("skips aren't available")
+	}
+	if b.Take() || b.Left() != 0 {
+		t.Fatalf("limit is exceeded, %d skips left", b.Left())
+	}
+	if avail := time.Until(b.Available()); avail <= 0 || avail > window {
+		t.Errorf("next skip is available in %s", avail)
+	}
+
+	// Skips are available again when they leave the window.
+	time.Sleep(window)
+	if left := b.Left(); left != 2 {
+		t.Errorf("%d skips left after the window, want 2", left)
+	}
+	if !b.Available().IsZero() || !b.Take() {
+		t.Error("skip isn't available after the window")
+	}
+}
+
+func TestSkipBudgetUnlimited(t *testing.T) {
+	b := NewSkipBudget(0, time.Hour)
+	for i := 0; i < FreeSkips*2; i++ {
+		if !b.Take() {
+			t.Fatal("unlimited budget refuses to skip")
+		}
+	}
+	if b.Left() != -1 || !b.Available().IsZero() {
+		t.Errorf("unlimited budget has %d skips left", b.Left())
+	}
+}
@@ -1,39 +1,88 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"os/exec"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	kb "github.com/koykov/helpers/keybind"
 	v "github.com/koykov/helpers/verbose"
-	"github.com/koykov/vlc"
 	"github.com/mikkyang/id3-go"
 
 	"github.com/koykov/conply"
+	"github.com/koykov/conply/vlc"
 )
 
 const (
 	Bundle      = "xradio"
 	Version     = "v0.1"
 	CacheExpire = 7 * 24 * 3600
+	ProxyAddr   = "127.0.0.1:8102"
+)
+
+// Default hotkeys. Keys with "Term-" prefix are used in terminal when X server is unavailable.
+var defaultHotkeys = []*kb.Hotkey{
+	{"Pause", "sig-toggle-pause"},
+	{"Control-Shift-k", "sig-toggle-pause"},
+	{"Control-Shift-l", "sig-next"},
+	{"Control-Shift-h", "sig-prev"},
+	{"Control-Shift-r", "sig-replay"},
+	{"Control-Shift-d", "sig-download"},
+	{"Control-Shift-f", "sig-favorite"},
+	{"Control-Shift-y", "sig-like"},
+	{"Control-Shift-x", "sig-dislike"},
+	{"Control-Shift-Up", "sig-volume-up"},
+	{"Control-Shift-Down", "sig-volume-down"},
+	{"Control-Shift-m", "sig-mute"},
+	{"Control-Shift-s", "sig-sleep"},
+	{"Term-space", "sig-toggle-pause"},
+	{"Term-n", "sig-next"},
+	{"Term-b", "sig-prev"},
+	{"Term-r", "sig-replay"},
+	{"Term-d", "sig-download"},
+	{"Term-f", "sig-favorite"},
+	{"Term-y", "sig-like"},
+	{"Term-x", "sig-dislike"},
+	{"Term-+", "sig-volume-up"},
+	{"Term--", "sig-volume-down"},
+	{"Term-m", "sig-mute"},
+	{"Term-s", "sig-sleep"},
+	{"Term-q", conply.SigQuit},
+}
+
+var (
+	ErrSkipLimit   = errors.New("skip limit is reached")
+	ErrAllDisliked = errors.New("all tracks of the chunk are disliked")
 )
 
 // Xradio player.
 type Player struct {
+	muxTok  sync.RWMutex
 	atoken  string
+	account *Account
 	station *Station
 	cache   ChannelsCache
+	groups  ChannelFilters
 	channel *Channel
 	track   *Track
+	favs    conply.Favorites
 	chIdx   uint64
+	played  Played
+	skips   *SkipBudget
+	// Tracks skipped automatically, nil in the stream proxy.
+	dislikes *Dislikes
 
 	vlc      *vlc.Vlc
 	status   conply.Status
@@ -41,29 +90,50 @@ type Player struct {
 	signals  map[string]chan bool
 	sigUtime int64
 	muxDl    sync.Mutex
+	dl       conply.Downloads
+	muxUp    sync.Mutex
+	upcoming []conply.TrackInfo
+	// Crossfade between tracks of the chunk, zero means gapless playing.
+	crossfade time.Duration
+
+	events conply.Events
+	info   *conply.TrackInfo
+	np     conply.NowPlaying
+
+	// Channel requested by remote control.
+	switchTo chan uint64
+
+	sleepTimer *conply.SleepTimer
+	notifier   *conply.Notifier
+	// Duration of fade in of the next played track, used on wake up.
+	wakeRamp time.Duration
 
 	verbose *v.Verbose
 }
 
 // The constructor.
 func NewPlayer(verbose *v.Verbose, options map[string]interface{}) *Player {
+	// Missing crossfade means gapless playing.
+	crossfade, _ := options["crossfade"].(time.Duration)
 	ply := Player{
 		station: options["station"].(*Station),
 		cache:   make(ChannelsCache, 0),
-		chIdx:   options["channel"].(uint64),
 		status:  conply.StatusPlay,
 		ticks: map[string]<-chan time.Time{
-			"track": make(chan time.Time),
-			"token": make(chan time.Time),
-			"next":  make(chan time.Time),
+			"next": make(chan time.Time),
 		},
+		// Signals are buffered to not block callers while the playing loop is busy, see signal.
 		signals: map[string]chan bool{
-			"next": make(chan bool),
+			"next":   make(chan bool, 1),
+			"prev":   make(chan bool, 1),
+			"replay": make(chan bool, 1),
 		},
-		verbose: verbose,
+		crossfade: crossfade,
+		switchTo:  make(chan uint64, 1),
+		verbose:   verbose,
 	}
 
-	ply.verbose.Info(Bundle + " " + Version)
+	ply.sleepTimer = conply.NewSleepTimer(ply.sleep)
 
 	return &ply
 }
@@ -82,19 +152,12 @@ func (ply *Player) Init() error {
 	}
 	ply.verbose.Debug2("Environment is OK")
 
-	// Default hotkeys.
-	hotkeys := []*kb.Hotkey{
-		{"Pause", "sig-toggle-pause"},
-		{"Control-Shift-k", "sig-toggle-pause"},
-		{"Control-Shift-l", "sig-next"},
-		{"Control-Shift-d", "sig-download"},
-	}
 	// Check (and create if needed) hotkeys config file.
 	hkPath, _ := conply.GetHKPath(Bundle)
 	ply.verbose.Debug1("Reading hotkeys config data: ", hkPath)
 	if !conply.FileExists(hkPath) {
 		ply.verbose.Debug2("Hotkeys config data not found")
-		err := conply.MarshalFile(hkPath, hotkeys, true)
+		err := conply.MarshalFile(hkPath, defaultHotkeys, true)
 		if err != nil {
 			ply.verbose.Fail("Failed attempt of create hotkeys config")
 			return err
@@ -103,17 +166,104 @@ func (ply *Player) Init() error {
 		}
 	}
 
-	// Initialize VLC player.
+	// Load AudioAddict account.
 	var err error
+	if ply.account, err = AccountFromFile(AccountPath()); err != nil {
+		ply.verbose.Fail("Account reading problem")
+		return err
+	}
+	if ply.account.IsPremium() {
+		ply.verbose.Info("Premium streams are enabled")
+		ply.skips = NewSkipBudget(0, 0)
+	} else {
+		ply.skips = NewSkipBudget(FreeSkips, SkipWindow)
+	}
+
+	// Load favorite channels.
+	favPath, _ := conply.GetFavoritesPath(Bundle)
+	ply.verbose.Debug1("Reading favorites: ", favPath)
+	if ply.favs, err = conply.FavoritesFromFile(favPath); err != nil {
+		ply.verbose.Fail("Favorites reading problem")
+		return err
+	}
+
+	// Load disliked tracks.
+	ply.verbose.Debug1("Reading disliked tracks: ", DislikesPath())
+	if ply.dislikes, err = DislikesFromFile(DislikesPath()); err != nil {
+		ply.verbose.Fail("Disliked tracks reading problem")
+		return err
+	}
+
+	// Keep now playing state for remote control.
+	ply.events.Subscribe(ply.np.Listen)
+
+	// Write listening history.
+	histPath, _ := conply.GetHistoryPath(Bundle)
+	ply.events.Subscribe(conply.NewHistory(histPath).Listen)
+
+	// Submit played tracks to scrobbling services.
+	scrobbler, err := conply.LoadScrobbler(Bundle, func(err error) {
+		ply.verbose.Fail("Scrobbling failed due to error: ", err)
+	})
+	switch {
+	case err != nil:
+		ply.verbose.Fail("Scrobbling will unavailable during this session due to error: ", err)
+	case scrobbler != nil:
+		ply.events.Subscribe(scrobbler.Listen)
+		ply.verbose.Debug2("Scrobbling is enabled")
+	}
+
+	// Show desktop notifications.
+	ply.notifier, err = conply.LoadNotifier(Bundle, func(err error) {
+		ply.verbose.Debug1("Notification failed due to error: ", err)
+	})
+	switch {
+	case err != nil:
+		ply.verbose.Warning("Desktop notifications will unavailable during this session due to error: ", err)
+	case ply.notifier != nil:
+		ply.events.Subscribe(ply.notifier.Listen)
+		ply.verbose.Debug2("Desktop notifications are enabled")
+	}
+
+	// Initialize VLC player.
 	ply.verbose.Debug1("Initialize VLC")
 	if ply.vlc, err = vlc.NewVlc([]string{"--quiet", "--no-video"}); err != nil {
 		return err
 	}
+	ply.vlc.SetCrossfade(ply.crossfade)
 	ply.verbose.Debug2("VLC is ready")
 
+	// Restore the volume of the last session.
+	sessPath, _ := conply.GetSessionPath(Bundle)
+	vol, err := conply.VolumeFromSession(sessPath)
+	if err != nil {
+		ply.verbose.Warning("Couldn't restore the volume: ", err)
+	}
+	if err = ply.vlc.SetVolume(vol.Level); err == nil {
+		err = ply.vlc.Mute(vol.Muted)
+	}
+	if err != nil {
+		ply.verbose.Warning("Couldn't set the volume: ", err)
+	}
+	ply.emitVolume()
+
 	return nil
 }
 
+// Save the current channel, status and volume to resume them on the next start.
+// Channel of the saved session is kept if the channel wasn't chosen.
+func (ply *Player) SaveSession() error {
+	session := conply.Session{
+		Bundle:  Bundle,
+		Station: ply.station.Key,
+		Channel: ply.chIdx,
+		Paused:  ply.status == conply.StatusPause,
+		Volume:  conply.Volume{Level: ply.GetVolume(), Muted: ply.IsMuted()},
+	}
+	path, _ := conply.GetSessionPath(Bundle)
+	return session.Save(path)
+}
+
 // Release player resources.
 func (ply *Player) Release() error {
 	err := ply.vlc.Release()
@@ -122,11 +272,53 @@ func (ply *Player) Release() error {
 
 // Cleanup callback will call before finishing the work.
 func (ply *Player) Cleanup() (err error) {
+	if tui != nil {
+		_ = tui.Release()
+	}
+	if termKeys != nil {
+		_ = termKeys.Release()
+	}
 	ply.verbose.Debug1("Caught SIGTERM signal")
-	ply.verbose.Debug3("Release keybinding")
-	err = keybind.Release()
-	if err != nil {
-		return err
+	ply.finishTrack(false)
+	ply.verbose.Debug3("Save session state")
+	if err := ply.SaveSession(); err != nil {
+		ply.verbose.Fail("Couldn't save session state: ", err)
+	}
+	if keybind != nil {
+		ply.verbose.Debug3("Release keybinding")
+		if err = keybind.Release(); err != nil {
+			return err
+		}
+	}
+	if mpris != nil {
+		ply.verbose.Debug3("Release MPRIS interface")
+		if err = mpris.Release(); err != nil {
+			return err
+		}
+	}
+	if control != nil {
+		ply.verbose.Debug3("Release control socket")
+		if err = control.Release(); err != nil {
+			return err
+		}
+	}
+	if api != nil {
+		ply.verbose.Debug3("Stop HTTP API")
+		if err = api.Release(); err != nil {
+			return err
+		}
+	}
+	if ply.notifier != nil {
+		ply.verbose.Debug3("Release desktop notifications")
+		if err = ply.notifier.Release(); err != nil {
+			return err
+		}
+	}
+	if evOutput != nil {
+		ply.verbose.Debug3("Close events output")
+		if err = evOutput.Release(); err != nil {
+			return err
+		}
 	}
 	ply.verbose.Debug3("Release VLC player")
 	err = ply.Release()
@@ -164,9 +356,36 @@ func (ply *Player) Catch(signal string) error {
 		}
 
 	case "sig-next":
-		ply.signals["next"] <- true
+		if ply.skips.Left() == 0 {
+			ply.verbose.Warningf("Skip limit is reached, the next skip is available at %s", ply.skips.Available().Format("15:04"))
+			return ErrSkipLimit
+		}
+		// Pending skip isn't counted twice.
+		if !ply.signal("next") {
+			return conply.ErrMultipleCatch
+		}
+		ply.skips.Take()
+		if left := ply.skips.Left(); left >= 0 {
+			ply.verbose.Infof("Skips left: %d", left)
+		}
+	case "sig-like", "sig-dislike":
+		if ply.track == nil {
+			break
+		}
+		ply.vote(signal == "sig-like")
+	case "sig-prev":
+		if !ply.signal("prev") {
+			return conply.ErrMultipleCatch
+		}
+	case "sig-replay":
+		if !ply.signal("replay") {
+			return conply.ErrMultipleCatch
+		}
 	case "sig-download":
-		go func(ply *Player) {
+		if ply.info == nil {
+			break
+		}
+		go func(ply *Player, info conply.TrackInfo) {
 			err, warn := ply.Download()
 			switch {
 			case err != nil:
@@ -176,7 +395,30 @@ func (ply *Player) Catch(signal string) error {
 			default:
 				ply.verbose.Debug1("Track has been successfully downloaded")
 			}
-		}(ply)
+			if warn == nil {
+				ply.emit(conply.Event{Type: conply.EventDownload, Track: info, Err: err})
+			}
+		}(ply, *ply.info)
+	case "sig-favorite":
+		if err := ply.ToggleFavorite(); err != nil {
+			ply.verbose.Fail("Couldn't save favorites: ", err)
+		}
+	case "sig-volume-up", "sig-volume-down":
+		step := conply.VolumeStep
+		if signal == "sig-volume-down" {
+			step = -step
+		}
+		if err := ply.SetVolume(ply.GetVolume() + step); err != nil {
+			ply.verbose.Fail("Couldn't change the volume: ", err)
+		} else {
+			ply.verbose.Debug2f("Volume: %d%%", ply.GetVolume())
+		}
+	case "sig-mute":
+		if err := ply.Mute(!ply.IsMuted()); err != nil {
+			ply.verbose.Fail("Couldn't mute the player: ", err)
+		}
+	case "sig-sleep":
+		ply.notifySleep(ply.sleepTimer.Cycle())
 	}
 	return nil
 }
@@ -200,23 +442,76 @@ func (ply *Player) Play() (err error) {
 		return ply.vlc.Stop()
 	default:
 		ply.verbose.Debug3("Track URL: ", trackUrl)
-		ply.status = conply.StatusPlay
+		ply.setStatus(conply.StatusPlay)
+		fade := conply.FadeDuration
+		if ply.wakeRamp > 0 {
+			fade, ply.wakeRamp = ply.wakeRamp, 0
+		}
+		// Don't wait the buffering.
+		go func() {
+			if err := ply.vlc.FadeIn(fade); err != nil {
+				ply.verbose.Fail("Fade in failed due to error: ", err)
+			}
+		}()
 	}
 	return
 }
 
+// Play the next track of the chunk preloaded by Preload.
+// Tracks are crossfaded during the given duration, zero means gapless playing.
+// Falls back to Play if the track isn't preloaded or playing is paused.
+func (ply *Player) PlayNext(crossfade time.Duration) error {
+	if ply.status != conply.StatusPlay || !ply.vlc.Preloaded() {
+		ply.vlc.DropPreloaded()
+		ply.stopTrack(true)
+		return ply.Play()
+	}
+	ply.verbose.Debug3("Track URL: ", ply.track.GetURL())
+	return ply.vlc.PlayPreloaded(crossfade)
+}
+
+// Preload the track to play it by PlayNext without gap.
+func (ply *Player) Preload(track *Track) error {
+	ply.verbose.Debug3("Preload track URL: ", track.GetURL())
+	return ply.vlc.Preload(track.GetURL())
+}
+
+// Change the status and notify listeners about it.
+func (ply *Player) setStatus(status conply.Status) {
+	if ply.status == status {
+		return
+	}
+	ply.status = status
+	ply.emit(conply.Event{Type: conply.EventStatus, Status: status})
+}
+
 // Stop playing.
 func (ply *Player) Stop() error {
-	ply.status = conply.StatusStop
+	if ply.status == conply.StatusPlay {
+		_ = ply.vlc.FadeOut(conply.FadeDuration)
+	}
+	ply.setStatus(conply.StatusStop)
 	return ply.vlc.Stop()
 }
 
+// Stop the playing of current track keeping the status.
+// Interrupted track is faded out.
+func (ply *Player) stopTrack(interrupted bool) {
+	if interrupted && ply.status == conply.StatusPlay {
+		_ = ply.vlc.FadeOut(conply.FadeDuration)
+	}
+	_ = ply.vlc.Stop()
+}
+
 // Pause playing.
 func (ply *Player) Pause() error {
 	if ply.status == conply.StatusPause {
 		return nil
 	}
-	ply.status = conply.StatusPause
+	ply.setStatus(conply.StatusPause)
+	if err := ply.vlc.FadeOut(conply.FadeDuration); err != nil {
+		return err
+	}
 	return ply.vlc.Pause()
 }
 
@@ -225,8 +520,11 @@ func (ply *Player) Resume() error {
 	if ply.status == conply.StatusPlay {
 		return nil
 	}
-	ply.status = conply.StatusPlay
-	return ply.vlc.Resume()
+	ply.setStatus(conply.StatusPlay)
+	if err := ply.vlc.Resume(); err != nil {
+		return err
+	}
+	return ply.vlc.FadeIn(conply.FadeDuration)
 }
 
 // Get current status.
@@ -234,11 +532,84 @@ func (ply *Player) GetStatus() conply.Status {
 	return ply.status
 }
 
-// Download the track.
+// Set volume from 0 to 100.
+func (ply *Player) SetVolume(volume int) error {
+	if err := ply.vlc.SetVolume(volume); err != nil {
+		return err
+	}
+	ply.emitVolume()
+	return nil
+}
+
+// Get volume from 0 to 100.
+func (ply *Player) GetVolume() int {
+	return ply.vlc.Volume()
+}
+
+// Mute or unmute the player.
+func (ply *Player) Mute(mute bool) error {
+	if err := ply.vlc.Mute(mute); err != nil {
+		return err
+	}
+	ply.emitVolume()
+	return nil
+}
+
+// Check if the player is muted.
+func (ply *Player) IsMuted() bool {
+	return ply.vlc.Muted()
+}
+
+// Start the sleep timer, zero duration turns it off.
+func (ply *Player) SetSleep(d time.Duration) {
+	ply.notifySleep(ply.sleepTimer.Set(d))
+}
+
+// Report about the sleep timer and notify listeners.
+func (ply *Player) notifySleep(at time.Time) {
+	if at.IsZero() {
+		ply.verbose.Info("Sleep timer is off")
+	} else {
+		ply.verbose.Infof("Sleep timer is set, playing stops at %s", at.Format("15:04"))
+	}
+	ply.emit(conply.Event{Type: conply.EventSleep, SleepAt: at})
+}
+
+// Fade out and stop playing when the sleep timer is over.
+// Terminal UI keeps working to resume playing, otherwise the player exits.
+func (ply *Player) sleep() {
+	ply.verbose.Info("Sleep timer is over, good night")
+	ply.emit(conply.Event{Type: conply.EventSleep})
+	if ply.status == conply.StatusPlay {
+		_ = ply.vlc.FadeOut(conply.SleepFadeDuration)
+	}
+	if tui != nil {
+		ply.setStatus(conply.StatusPause)
+		_ = ply.vlc.Pause()
+		return
+	}
+	_ = syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+}
+
+// Download the track and track the job.
 func (ply *Player) Download() (error, error) {
+	job := ply.dl.Add(ply.trackInfo())
+	err, warn := ply.download(job)
+	job.Finish(err, warn)
+	return err, warn
+}
+
+// Get download jobs, see conply.Downloader.
+func (ply *Player) Downloads() []conply.DownloadJob {
+	return ply.dl.Jobs()
+}
+
+// Download the track.
+func (ply *Player) download(job *conply.DownloadJob) (error, error) {
 	// Make download process safety.
 	ply.muxDl.Lock()
 	defer ply.muxDl.Unlock()
+	job.Start()
 
 	channel := ply.cache.GetGroupById(ply.chIdx)
 
@@ -269,10 +640,24 @@ func (ply *Player) Download() (error, error) {
 	}
 
 	// Start ffmpeg and wait until it will download and convert the track.
-	cmd := exec.Command(ffmpegBin, "-i", url, dest)
+	// Progress is reported to stdout as key=value lines.
+	cmd := exec.Command(ffmpegBin, "-nostats", "-progress", "pipe:1", "-i", url, dest)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err, nil
+	}
 	if err := cmd.Start(); err != nil {
 		return err, nil
 	}
+	length := ply.track.Content.Length
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if us, ok := strings.CutPrefix(scanner.Text(), "out_time_us="); ok && length > 0 {
+			if n, err := strconv.ParseFloat(us, 64); err == nil {
+				job.SetProgress(math.Min(n/1e6/length, 1))
+			}
+		}
+	}
 	if err := cmd.Wait(); err != nil {
 		return err, nil
 	}
@@ -300,13 +685,204 @@ func (ply *Player) Download() (error, error) {
 	return nil, nil
 }
 
+// Get channels of the station grouped by filters, see conply.Navigator.
+// The same channel may be listed in several groups. Station without filters has the only group.
+func (ply *Player) Catalog() conply.Catalog {
+	if len(ply.groups) == 0 {
+		return conply.Catalog{ply.catalogGroup(0, ply.station.Key, ply.cache)}
+	}
+	cat := make(conply.Catalog, 0, len(ply.groups))
+	for _, f := range ply.groups {
+		cat = append(cat, ply.catalogGroup(f.Id, f.Title, ply.cache.Subset(f.Channels)))
+	}
+	return cat
+}
+
+// Build catalog group of the channels.
+func (ply *Player) catalogGroup(id uint64, title string, channels ChannelsCache) conply.CatalogGroup {
+	group := conply.CatalogGroup{Id: id, Title: title, Channels: make([]conply.CatalogChannel, 0, len(channels))}
+	for _, c := range channels {
+		group.Channels = append(group.Channels, conply.CatalogChannel{Id: c.Id, Title: c.Title, Slug: c.Slug})
+	}
+	return group
+}
+
+// Get favorite channels of the station, see conply.Navigator.
+func (ply *Player) Favorites() conply.Favorites {
+	return ply.favs.Station(ply.station.Key)
+}
+
+// Ask the playing loop to switch to the channel, see conply.Navigator.
+func (ply *Player) SwitchChannel(id uint64) error {
+	if ply.cache.GetGroupById(id).Id == 0 {
+		return conply.ErrUnknownChannel
+	}
+	select {
+	case ply.switchTo <- id:
+		return nil
+	default:
+		return conply.ErrSwitchPending
+	}
+}
+
+// Add the current channel to favorites or remove it from there.
+func (ply *Player) ToggleFavorite() error {
+	channel := ply.cache.GetGroupById(ply.chIdx)
+	if channel.Id == 0 {
+		return errors.New("undefined channel")
+	}
+	fav := &conply.Favorite{
+		Name:    conply.Slug(channel.Title),
+		Station: ply.station.Key,
+		Channel: channel.Id,
+		Title:   ply.station.Key + "/" + channel.Title,
+	}
+	added := ply.favs.Toggle(fav)
+	favPath, _ := conply.GetFavoritesPath(Bundle)
+	if err := conply.MarshalFile(favPath, ply.favs, true); err != nil {
+		return err
+	}
+	if added {
+		ply.verbose.Infof("Channel %s has been added to favorites as \"%s\"", fav.Title, fav.Name)
+	} else {
+		ply.verbose.Infof("Channel %s has been removed from favorites", fav.Title)
+	}
+	return nil
+}
+
+// Vote for the current track in background.
+// Disliked track is remembered and skipped right now, like removes the track from disliked ones.
+func (ply *Player) vote(like bool) {
+	track, channel, direction := *ply.track, ply.chIdx, VoteUp
+	title := track.Artist + " - " + track.Title
+	if like {
+		if ply.dislikes.Remove(ply.station.Key, track.Id) {
+			ply.saveDislikes()
+		}
+		ply.verbose.Info("Liked: ", title)
+	} else {
+		direction = VoteDown
+		if ply.dislikes.Add(ply.station.Key, channel, &track) {
+			ply.saveDislikes()
+		}
+		ply.verbose.Info("Disliked, the track won't be played anymore: ", title)
+		// Disliked track isn't counted as a skip.
+		ply.signal("next")
+	}
+	go func() {
+		if err := ply.Vote(&track, channel, direction); err != nil {
+			ply.verbose.Warning("Couldn't submit the vote: ", err)
+		}
+	}()
+}
+
+// Send the signal to the playing loop without waiting for it. Returns false if the same signal is still pending.
+func (ply *Player) signal(name string) bool {
+	select {
+	case ply.signals[name] <- true:
+		return true
+	default:
+		return false
+	}
+}
+
+// Drop pending signals, eg they aren't related to the tracks of other channel.
+func (ply *Player) dropSignals() {
+	for _, ch := range ply.signals {
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// Save disliked tracks and report about errors.
+func (ply *Player) saveDislikes() {
+	if err := ply.dislikes.Save(DislikesPath()); err != nil {
+		ply.verbose.Fail("Couldn't save disliked tracks: ", err)
+	}
+}
+
+// Build bundle independent info of the current track.
+func (ply *Player) trackInfo() conply.TrackInfo {
+	return ply.describe(ply.track)
+}
+
+// Build bundle independent info of the track of the current channel.
+func (ply *Player) describe(track *Track) conply.TrackInfo {
+	channel := ply.cache.GetGroupById(ply.chIdx)
+	return conply.TrackInfo{
+		Bundle:    Bundle,
+		Station:   ply.station.Key,
+		Channel:   channel.Title,
+		ChannelId: channel.Id,
+		Id:        strconv.FormatUint(track.Id, 10),
+		Artist:    track.Artist,
+		Title:     track.Title,
+		Album:     track.Album,
+		ArtURL:    track.ArtURL,
+		Length:    track.Content.Length,
+	}
+}
+
+// Remember tracks to play after the current one.
+func (ply *Player) setUpcoming(tracks []Track) {
+	upcoming := make([]conply.TrackInfo, 0, len(tracks))
+	for i := range tracks {
+		upcoming = append(upcoming, ply.describe(&tracks[i]))
+	}
+	ply.muxUp.Lock()
+	ply.upcoming = upcoming
+	ply.muxUp.Unlock()
+}
+
+// Get tracks of the chunk to play after the current one, see conply.Scheduler.
+func (ply *Player) Upcoming() []conply.TrackInfo {
+	ply.muxUp.Lock()
+	defer ply.muxUp.Unlock()
+	return ply.upcoming
+}
+
+// Notify listeners that the current track has started.
+func (ply *Player) startTrack() {
+	info := ply.trackInfo()
+	ply.info = &info
+	ply.emit(conply.Event{Type: conply.EventTrackStart, Track: info})
+}
+
+// Notify listeners that the current track has finished or skipped.
+func (ply *Player) finishTrack(skipped bool) {
+	if ply.info == nil {
+		return
+	}
+	info := *ply.info
+	ply.info = nil
+	ply.emit(conply.Event{Type: conply.EventTrackFinish, Track: info, Skipped: skipped})
+}
+
+// Notify listeners about changed volume.
+func (ply *Player) emitVolume() {
+	ply.emit(conply.Event{Type: conply.EventVolume, Volume: ply.GetVolume(), Muted: ply.IsMuted()})
+}
+
+// Send the event to listeners and report about errors.
+func (ply *Player) emit(e conply.Event) {
+	if err := ply.events.Emit(e); err != nil {
+		ply.verbose.Fail("Event handling failed due to error: ", err)
+	}
+}
+
 // Sets the current track to play.
 func (ply *Player) SetTrack(track *Track) {
 	ply.track = track
 }
 
 // Fetch fresh audio token.
+// Premium members are authorized by the listen key, so the token isn't needed.
 func (ply *Player) RetrieveAToken() error {
+	if ply.account.IsPremium() {
+		return nil
+	}
 	response, err := http.Get(ply.station.Station)
 	if err != nil {
 		return err
@@ -326,10 +902,19 @@ func (ply *Player) RetrieveAToken() error {
 		return errors.New("couldn't parse remote site to retrieve audio token")
 	}
 
+	ply.muxTok.Lock()
 	ply.atoken = res[1]
+	ply.muxTok.Unlock()
 	return nil
 }
 
+// Get the current audio token, it's refreshed in background.
+func (ply *Player) token() string {
+	ply.muxTok.RLock()
+	defer ply.muxTok.RUnlock()
+	return ply.atoken
+}
+
 // Get list of channels from remote site.
 func (ply *Player) RetrieveChannels() error {
 	response, err := http.Get(ply.station.Station)
@@ -345,10 +930,10 @@ func (ply *Player) RetrieveChannels() error {
 		return err
 	}
 
+	var app App
 	if m := re.FindSubmatch(buf); m != nil {
 		// First try to get the app json config.
 		ply.verbose.Debug3("Way #1 was chosen to retrieve the channels.")
-		var app App
 		err = json.Unmarshal(m[1], &app)
 		if err != nil {
 			return err
@@ -392,38 +977,138 @@ func (ply *Player) RetrieveChannels() error {
 	// Sort channels for pretty view.
 	sort.Sort(&ply.cache)
 
+	// Group channels by filters of the site, API is asked if the site doesn't provide them.
+	filters := app.ChannelFilters
+	if len(filters) == 0 {
+		ply.verbose.Debug3("Looking for channel filters in API")
+		if filters, err = ply.RetrieveFilters(); err != nil {
+			ply.verbose.Warning("Channels will be shown without groups due to error: ", err)
+		}
+	}
+	ply.groups = NewChannelFilters(filters, ply.cache)
+
 	return nil
 }
 
-// Get chunk of tracks for nearest ~1/2h.
+// Get channel filters of the station from API.
+func (ply *Player) RetrieveFilters() ([]AppFilter, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s/channel_filters", ply.station.API, ply.station.Key), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(apiUser, apiPassword)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("channel filters: " + resp.Status)
+	}
+	var filters []AppFilter
+	if err := json.NewDecoder(resp.Body).Decode(&filters); err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+// Get list of channels from the cache or remote site if cache is invalid or expired.
+func (ply *Player) LoadChannels(noCache bool) error {
+	cacheFile, _ := conply.GetChannelsPathWS(Bundle, ply.station.Key)
+	filtersFile, _ := conply.GetChannelsPathWS(Bundle, ply.station.Key+"-filters")
+	ply.verbose.Debug2("Look for channels in cache ", cacheFile)
+	regenRequire := !conply.FileExists(cacheFile) || conply.FileAge(cacheFile) > CacheExpire || noCache ||
+		!conply.FileExists(filtersFile)
+	if !regenRequire {
+		var err error
+		ply.verbose.Debug2("Get channels from cache")
+		if ply.cache, err = ChannelsFromCache(cacheFile); err != nil {
+			return err
+		}
+		if ply.groups, err = FiltersFromCache(filtersFile); err != nil {
+			return err
+		}
+		ply.verbose.Debug3f("Channels list has been retrieved from cache, total retrieved: %d", len(ply.cache))
+		return nil
+	}
+
+	ply.verbose.Debug2(`Cache is invalid or expired or "--no-cache" options has applied, try to regenerate it`)
+	ply.verbose.Debug2("Looking for channels list in remote site")
+	if err := ply.RetrieveChannels(); err != nil {
+		return err
+	}
+	ply.verbose.Debug2f("Channels list has been retrieved from remote site, total retrieved: %d", len(ply.cache))
+	err := conply.MarshalFile(cacheFile, ply.cache, true)
+	if err == nil {
+		err = conply.MarshalFile(filtersFile, ply.groups, true)
+	}
+	if err != nil {
+		ply.verbose.Fail("Writing cache error: ", err)
+	} else {
+		ply.verbose.Debug3("Channels list has been saved in cache file ", cacheFile)
+	}
+	return nil
+}
+
+// Get chunk of tracks for nearest ~1/2h and make it current.
 func (ply *Player) RetrieveTracks() error {
-	if len(ply.atoken) == 0 {
-		return errors.New("invalid access token")
+	channel, err := ply.FetchTracks()
+	if err != nil {
+		return err
+	}
+	ply.channel = channel
+	return nil
+}
+
+// Get chunk of tracks for nearest ~1/2h.
+func (ply *Player) FetchTracks() (*Channel, error) {
+	auth := "audio_token=" + ply.token()
+	if ply.account.IsPremium() {
+		auth = "listen_key=" + ply.account.ListenKey
+	} else if len(ply.token()) == 0 {
+		return nil, errors.New("invalid access token")
 	}
 
 	ts := time.Now().UnixNano() / 1000000
-	channelUrl := fmt.Sprintf("%s/%s/routines/channel/%d?audio_token=%s&_=%d", ply.station.API, ply.station.Key, ply.chIdx, ply.atoken, ts)
+	channelUrl := fmt.Sprintf("%s/%s/routines/channel/%d?%s&_=%d", ply.station.API, ply.station.Key, ply.chIdx, auth, ts)
 	response, err := http.Get(channelUrl)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() { _ = response.Body.Close() }()
 
 	buf, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var channel Channel
 	err = conply.Unmarshal(string(buf), &channel)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if ply.dislikes != nil {
+		total := len(channel.Tracks)
+		channel.Tracks = ply.dislikes.Filter(ply.station.Key, channel.Tracks)
+		if n := total - len(channel.Tracks); n > 0 {
+			ply.verbose.Debug1f("%d disliked tracks skipped", n)
+		}
+		// Empty chunk would be refetched immediately, so it's retried as failed one.
+		if total > 0 && len(channel.Tracks) == 0 {
+			return nil, ErrAllDisliked
+		}
 	}
 	for i, track := range channel.Tracks {
-		channel.Tracks[i].Content.Assets[0].Url = "https:" + track.Content.Assets[0].Url
+		for j, asset := range track.Content.Assets {
+			channel.Tracks[i].Content.Assets[j].Url = "https:" + asset.Url
+		}
+		if ply.account.IsPremium() {
+			channel.Tracks[i].Content.PreferBest()
+		}
+		if strings.HasPrefix(track.ArtURL, "//") {
+			channel.Tracks[i].ArtURL = "https:" + track.ArtURL
+		}
 		channel.Length += track.Content.Length
 	}
 
-	ply.channel = &channel
-
-	return nil
+	return &channel, nil
 }
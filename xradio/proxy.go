@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koykov/conply"
+)
+
+// Serve channels of all stations as endless mp3 streams to make them playable in third-party players.
+// Tracks are converted on the fly by ffmpeg.
+func serveProxy(addr string) error {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return errors.New("stream proxy requires ffmpeg installed")
+	}
+	acc, err := AccountFromFile(AccountPath())
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		proxyChannel(w, r, acc)
+	})
+	verbose.Infof("Stream proxy is listening on http://%s", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// Stream tracks of the channel one by one while the client is connected.
+// Expected path is /<station key>/<channel ID>. Premium streams are used if the account is given.
+func proxyChannel(w http.ResponseWriter, r *http.Request, acc *Account) {
+	chunks := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(chunks) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	st := stations.Look(chunks[0])
+	if st == nil {
+		http.Error(w, "unknown station", http.StatusNotFound)
+		return
+	}
+	cid, err := strconv.ParseUint(chunks[1], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid channel ID", http.StatusBadRequest)
+		return
+	}
+	verbose.Debug1f("Client %s connected to %s/%d", r.RemoteAddr, st.Key, cid)
+	defer verbose.Debug1f("Client %s disconnected from %s/%d", r.RemoteAddr, st.Key, cid)
+
+	p := NewPlayer(verbose, conply.Options{"station": st})
+	p.chIdx, p.account = cid, acc
+	w.Header().Set("Content-Type", "audio/mpeg")
+	attempts := 0
+	for {
+		// Each chunk requires fresh audio token.
+		err := p.RetrieveAToken()
+		if err == nil {
+			err = p.RetrieveTracks()
+		}
+		if err != nil {
+			attempts++
+			verbose.Failf("Couldn't retrieve tracks for %s/%d: %s", st.Key, cid, err)
+			if attempts >= 3 {
+				return
+			}
+			time.Sleep(time.Second * 5)
+			continue
+		}
+		attempts = 0
+
+		for _, track := range p.channel.Tracks {
+			verbose.Debug2f("%s/%d: %s", st.Key, cid, track.ComposeTitle())
+			// Client reads the stream in real time, so ffmpeg will wait for it.
+			cmd := exec.CommandContext(r.Context(), "ffmpeg", "-loglevel", "error", "-i", track.GetURL(), "-f", "mp3", "-")
+			cmd.Stdout = w
+			if err := cmd.Run(); err != nil {
+				verbose.Debug2f("%s/%d: streaming stopped due to error: %s", st.Key, cid, err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Audio token is refreshed this time before it expires.
+	TokenMargin = time.Minute
+	// Number of attempts to get the token when it's required right now.
+	tokenAttempts = 5
+	// Limits of delay between failed attempts to get the token.
+	retryMin = 2 * time.Second
+	retryMax = time.Minute
+)
+
+// Session keeps the audio token fresh and prefetches the next chunk of tracks.
+//
+// Expiry of the token is taken from the last chunk of tracks, the token is refreshed in background shortly before it.
+// Failed refreshes are retried with backoff, so playing of the current chunk is never interrupted.
+type Session struct {
+	ply *Player
+	mux sync.Mutex
+	// Expiry of the audio token, zero if the token hasn't been retrieved yet.
+	expiry time.Time
+	timer  *time.Timer
+
+	// Prefetching of the next chunk. Generation is changed by Drop to ignore outdated prefetches.
+	gen     int
+	done    chan struct{}
+	next    *Channel
+	nextErr error
+	ready   chan *Channel
+}
+
+// The constructor.
+func NewSession(ply *Player) *Session {
+	return &Session{ply: ply, ready: make(chan *Channel, 1)}
+}
+
+// Get fresh audio token right now. Failed attempts are retried with backoff a few times.
+func (s *Session) Refresh() error {
+	var err error
+	delay := retryMin
+	for i := 0; i < tokenAttempts; i++ {
+		if err = s.refresh(); err == nil {
+			return nil
+		}
+		s.ply.verbose.Warningf("Couldn't retrieve audio token: %s. Next attempt after %s", err, delay)
+		time.Sleep(delay)
+		delay = min(delay*2, retryMax)
+	}
+	return err
+}
+
+func (s *Session) refresh() error {
+	if err := s.ply.RetrieveAToken(); err != nil {
+		return err
+	}
+	s.mux.Lock()
+	// Real expiry is unknown until the next chunk is retrieved, refresh scheduled for the old token isn't needed.
+	s.expiry = time.Time{}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mux.Unlock()
+	s.ply.verbose.Debug2("Audio token retrieved: ", s.ply.token())
+	return nil
+}
+
+// Check the token is missing or about to expire.
+func (s *Session) expired() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.ply.token()) == 0 && !s.ply.account.IsPremium() ||
+		!s.expiry.IsZero() && time.Until(s.expiry) < TokenMargin
+}
+
+// Schedule background refresh of the token before it expires.
+// Chunks retrieved by the same token may report later expiry, the earliest one is kept to be on the safe side.
+func (s *Session) schedule(expiry time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.expiry.IsZero() && !expiry.Before(s.expiry) {
+		return
+	}
+	s.expiry = expiry
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(max(time.Until(expiry)-TokenMargin, 0), s.keepFresh)
+	s.ply.verbose.Debug1f("Audio token expires at %s", expiry.Format("15:04:05"))
+}
+
+// Refresh the token in background until success.
+func (s *Session) keepFresh() {
+	s.ply.verbose.Debug1("Audio token is about to expire, try to get fresh one")
+	delay := retryMin
+	for {
+		err := s.refresh()
+		if err == nil {
+			return
+		}
+		s.ply.verbose.Warningf("Couldn't refresh audio token: %s. Next attempt after %s", err, delay)
+		time.Sleep(delay)
+		delay = min(delay*2, retryMax)
+		if !s.expired() {
+			// Token has been refreshed meanwhile, eg by channel switching.
+			return
+		}
+	}
+}
+
+// Get chunk of tracks of the current channel, the token is refreshed first if it's expired.
+// Background refresh of the token is scheduled by expiry of the chunk.
+func (s *Session) Fetch() (*Channel, error) {
+	if s.expired() {
+		if err := s.Refresh(); err != nil {
+			return nil, err
+		}
+	}
+	channel, err := s.ply.FetchTracks()
+	if err != nil {
+		return nil, err
+	}
+	s.schedule(channel.Expires())
+	return channel, nil
+}
+
+// Start to fetch the next chunk in background. Ready channel receives it when it's fetched.
+func (s *Session) Prefetch() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.done != nil {
+		return
+	}
+	gen, done := s.gen, make(chan struct{})
+	s.done = done
+	go func() {
+		defer close(done)
+		channel, err := s.Fetch()
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		if gen != s.gen {
+			return
+		}
+		s.next, s.nextErr = channel, err
+		if err == nil {
+			select {
+			case s.ready <- channel:
+			default:
+			}
+		}
+	}()
+}
+
+// Get the next chunk fetched in background.
+func (s *Session) Ready() <-chan *Channel {
+	return s.ready
+}
+
+// Get the next chunk. Waits for prefetching if it's in progress or fetches the chunk right now.
+func (s *Session) Next() (*Channel, error) {
+	s.mux.Lock()
+	done := s.done
+	s.mux.Unlock()
+	if done == nil {
+		return s.Fetch()
+	}
+	<-done
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	channel, err := s.next, s.nextErr
+	s.reset()
+	return channel, err
+}
+
+// Forget the prefetched chunk, eg when the channel is switched.
+func (s *Session) Drop() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.reset()
+}
+
+func (s *Session) reset() {
+	s.gen++
+	s.done, s.next, s.nextErr = nil, nil, nil
+	select {
+	case <-s.ready:
+	default:
+	}
+}
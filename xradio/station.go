@@ -1,35 +1,135 @@
 package main
 
-import "strings"
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/koykov/conply"
+)
+
+// Registry of AudioAddict networks shipped with the player.
+//
+//go:embed networks.json
+var networksJSON []byte
+
+// AudioAddict network.
 type Station struct {
-	Alias   string
-	Key     string
-	Station string
-	API     string
+	// Network key, it's used in API requests and identifies the station in favorites, sessions and history.
+	Key string `json:"key"`
+	// Site URL.
+	Station string `json:"url"`
+	// API URL.
+	API string `json:"api"`
+	// Additional names of the station, the key is an alias as well.
+	Aliases []string `json:"aliases,omitempty"`
 }
 
 type Stations []Station
 
-// Search station alias in registry of stations.
+// Load registry of stations.
+// Networks from the user file extend the bundled ones, network with the same key replaces the bundled one.
+func LoadStations() (Stations, error) {
+	stations := Stations{}
+	if err := json.Unmarshal(networksJSON, &stations); err != nil {
+		return nil, err
+	}
+	path := StationsPath()
+	if !conply.FileExists(path) {
+		return stations, nil
+	}
+	user := Stations{}
+	if err := conply.UnmarshalFile(path, &user); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, st := range user {
+		if len(st.Key) == 0 || len(st.Station) == 0 || len(st.API) == 0 {
+			return nil, fmt.Errorf("%s: network %#v requires key, url and api", path, st.Key)
+		}
+		st.Station, st.API = strings.TrimSuffix(st.Station, "/"), strings.TrimSuffix(st.API, "/")
+		if i := stations.index(st.Key); i >= 0 {
+			stations[i] = st
+		} else {
+			stations = append(stations, st)
+		}
+	}
+	return stations, nil
+}
+
+// Get path to user registry of stations.
+func StationsPath() string {
+	path, _ := conply.GetConfigDir(Bundle)
+	return path + conply.PS + "networks.json"
+}
+
+// Search station by key or alias in registry of stations.
 func (s *Stations) Look(alias string) *Station {
-	for _, st := range *s {
-		if st.Alias == alias {
-			return &st
+	if i := s.index(alias); i >= 0 {
+		return &(*s)[i]
+	}
+	for i, st := range *s {
+		for _, a := range st.Aliases {
+			if a == alias {
+				return &(*s)[i]
+			}
 		}
 	}
 	return nil
 }
 
+func (s *Stations) index(key string) int {
+	for i, st := range *s {
+		if st.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
 // Build a human readable list of a stations.
 func (s *Stations) PrettyPrint() string {
 	list := make([]string, 0)
 	for _, st := range *s {
-		list = append(list, "  "+st.Alias)
+		line := "  " + st.Key
+		if len(st.Aliases) > 0 {
+			line += " (" + strings.Join(st.Aliases, ", ") + ")"
+		}
+		list = append(list, line)
 	}
 	return strings.Join(list, "\n")
 }
 
+// Write the stations to w in given format, see conply.Catalog.Write.
+// TSV contains one station per line with fields key, comma separated aliases and URL.
+// JSON has the same format as the registry file.
+func (s *Stations) Write(w io.Writer, format string) error {
+	switch strings.ToLower(format) {
+	case conply.CatalogTable, "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "STATION\tALIASES\tURL")
+		for _, st := range *s {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", st.Key, strings.Join(st.Aliases, ", "), st.Station)
+		}
+		return tw.Flush()
+	case conply.CatalogTSV:
+		for _, st := range *s {
+			if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", st.Key, strings.Join(st.Aliases, ","), st.Station); err != nil {
+				return err
+			}
+		}
+		return nil
+	case conply.CatalogJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(s)
+	default:
+		return conply.ErrUnknownFormat
+	}
+}
+
 // Implement fmt.Stringer
 func (s *Station) String() string {
 	return s.Station
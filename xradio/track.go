@@ -14,6 +14,7 @@ type Track struct {
 	Title     string  `json:"display_title"`
 	Album     string  `json:"release"`
 	AlbumDate string  `json:"release_date"`
+	ArtURL    string  `json:"art_url"`
 	Content   Content `json:"content"`
 }
 
@@ -26,6 +27,22 @@ type Content struct {
 // Asset.
 type Asset struct {
 	Url string `json:"url"`
+	// The higher the better, premium members get high bitrate assets.
+	Quality int `json:"content_quality_id"`
+}
+
+// Move the best quality asset to the first place to play it.
+func (c *Content) PreferBest() {
+	if len(c.Assets) == 0 {
+		return
+	}
+	best := 0
+	for i, asset := range c.Assets {
+		if asset.Quality > c.Assets[best].Quality {
+			best = i
+		}
+	}
+	c.Assets[0], c.Assets[best] = c.Assets[best], c.Assets[0]
 }
 
 // Build track's title to display it.
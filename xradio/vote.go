@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/koykov/conply"
+)
+
+const (
+	VoteUp   = "up"
+	VoteDown = "down"
+)
+
+var ErrNoAccount = errors.New("voting requires AudioAddict account, run \"xradio login\"")
+
+// Submit the vote for the track played on the channel, AudioAddict takes votes into account to program the channels.
+func (ply *Player) Vote(track *Track, channel uint64, direction string) error {
+	if ply.account == nil || len(ply.account.ListenKey) == 0 {
+		return ErrNoAccount
+	}
+	voteUrl := fmt.Sprintf("%s/%s/tracks/%d/vote/%d/%s?listen_key=%s",
+		ply.station.API, ply.station.Key, track.Id, channel, direction, ply.account.ListenKey)
+	req, err := http.NewRequest(http.MethodPost, voteUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(apiUser, apiPassword)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("vote failed: " + resp.Status)
+	}
+	return nil
+}
+
+// Disliked track, it's skipped automatically when the channel plays it again.
+type Disliked struct {
+	Station string    `json:"station"`
+	Channel uint64    `json:"channel"`
+	Id      uint64    `json:"id"`
+	Artist  string    `json:"artist"`
+	Title   string    `json:"title"`
+	Time    time.Time `json:"time"`
+}
+
+// List of disliked tracks. It's safe for concurrent use since chunks are prefetched in background.
+type Dislikes struct {
+	mux  sync.RWMutex
+	list []Disliked
+}
+
+// Get path to the list of disliked tracks.
+func DislikesPath() string {
+	path, _ := conply.GetConfigDir(Bundle)
+	return path + conply.PS + "dislikes.json"
+}
+
+// Load disliked tracks. Empty list is returned if the file doesn't exists.
+func DislikesFromFile(path string) (*Dislikes, error) {
+	d := Dislikes{list: make([]Disliked, 0)}
+	if !conply.FileExists(path) {
+		return &d, nil
+	}
+	if err := conply.UnmarshalFile(path, &d.list); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Save the list to the file.
+func (d *Dislikes) Save(path string) error {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+	return conply.MarshalFile(path, d.list, true)
+}
+
+// Add the track to the list. Returns false if the track is already there.
+func (d *Dislikes) Add(station string, channel uint64, track *Track) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.index(station, track.Id) >= 0 {
+		return false
+	}
+	d.list = append(d.list, Disliked{
+		Station: station,
+		Channel: channel,
+		Id:      track.Id,
+		Artist:  track.Artist,
+		Title:   track.Title,
+		Time:    time.Now(),
+	})
+	return true
+}
+
+// Remove the track from the list. Returns false if the track isn't there.
+func (d *Dislikes) Remove(station string, id uint64) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	i := d.index(station, id)
+	if i < 0 {
+		return false
+	}
+	d.list = append(d.list[:i], d.list[i+1:]...)
+	return true
+}
+
+// Check the track is disliked.
+func (d *Dislikes) Has(station string, id uint64) bool {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+	return d.index(station, id) >= 0
+}
+
+// Remove disliked tracks from the list of tracks.
+func (d *Dislikes) Filter(station string, tracks []Track) []Track {
+	res := tracks[:0]
+	for _, track := range tracks {
+		if !d.Has(station, track.Id) {
+			res = append(res, track)
+		}
+	}
+	return res
+}
+
+func (d *Dislikes) index(station string, id uint64) int {
+	for i, dis := range d.list {
+		if dis.Station == station && dis.Id == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestVote(t *testing.T) {
+	var path, key string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		if user, pass, _ := r.BasicAuth(); user != apiUser || pass != apiPassword {
+			t.Errorf("unexpected API credentials %q:%q", user, pass)
+		}
+		path, key = r.URL.Path, r.URL.Query().Get("listen_key")
+		if key != "key" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	ply := &Player{
+		station: &Station{Key: "di", API: srv.URL + "/api"},
+		account: &Account{ListenKey: "key"},
+	}
+	if err := ply.Vote(&Track{Id: 42}, 3, VoteDown); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/api/di/tracks/42/vote/3/down" {
+		t.Errorf("unexpected vote path %q", path)
+	}
+
+	ply.account.ListenKey = "wrong"
+	if err := ply.Vote(&Track{Id: 42}, 3, VoteUp); err == nil {
+		t.Error("expected error of rejected vote")
+	}
+}
+
+func TestVoteNoAccount(t *testing.T) {
+	ply := &Player{station: &Station{Key: "di", API: "http://127.0.0.1:0"}}
+	if err := ply.Vote(&Track{Id: 42}, 3, VoteUp); !errors.Is(err, ErrNoAccount) {
+		t.Errorf("error %v, want ErrNoAccount", err)
+	}
+}
+
+func TestDislikes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dislikes.json")
+	d, err := DislikesFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Add("di", 3, &Track{Id: 1, Artist: "Artist", Title: "Title"}) || d.Add("di", 5, &Track{Id: 1}) {
+		t.Fatal("track is added twice")
+	}
+	// The same ID of another network is a different track.
+	d.Add("jazzradio", 7, &Track{Id: 2})
+	if !d.Has("di", 1) || d.Has("di", 2) || !d.Has("jazzradio", 2) {
+		t.Error("unexpected dislikes")
+	}
+	if err := d.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if d, err = DislikesFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	tracks := d.Filter("di", []Track{{Id: 1}, {Id: 2}, {Id: 3}})
+	if len(tracks) != 2 || tracks[0].Id != 2 || tracks[1].Id != 3 {
+		t.Errorf("unexpected filtered tracks %+v", tracks)
+	}
+	if !d.Remove("di", 1) || d.Remove("di", 1) || d.Has("di", 1) {
+		t.Error("track isn't removed")
+	}
+}